@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -10,6 +12,11 @@ type Config struct {
 	Database DatabaseConfig
 	JWT      JWTConfig
 	Cache    CacheConfig
+	Play     PlayConfig
+	Schedule ScheduleConfig
+	Rating   RatingConfig
+	Review   ReviewConfig
+	Admin    AdminConfig
 }
 
 type ServerConfig struct {
@@ -36,6 +43,37 @@ type CacheConfig struct {
 	CleanupInterval   time.Duration
 }
 
+// PlayConfig 陪玩记录相关的业务时限
+type PlayConfig struct {
+	ConfirmWindow time.Duration // 完成后玩家确认/申诉的窗口期，到期自动确认
+	MaxActive     time.Duration // 进行中的最长时长，超过视为滞留
+	StaleAction   string        // 滞留处理方式：flag 仅标记并通知 / cancel 自动取消
+	AmendWindow   time.Duration // 完成后允许修正时长/数额的时限
+	IdleTimeout   time.Duration // 已启用活动监测的进行中记录，超过该时长无事件/心跳即标记为无活动
+}
+
+// ScheduleConfig 后台定时任务配置
+type ScheduleConfig struct {
+	Interval time.Duration // 轮询间隔
+}
+
+// RatingConfig 排名分（贝叶斯平滑 + 时间衰减）参数
+type RatingConfig struct {
+	PriorWeight  float64       // 向平台均分平滑的先验权重（相当于多少条「平均评价」）
+	HalfLife     time.Duration // 评价权重的半衰期
+	RefreshAfter time.Duration // 排名分超过该时长未刷新时由定时任务重算，以反映时间衰减
+}
+
+// ReviewConfig 评价相关时限
+type ReviewConfig struct {
+	EditWindow time.Duration // 评价提交后允许玩家修改的时限
+}
+
+// AdminConfig 平台管理员配置
+type AdminConfig struct {
+	Usernames []string // 启动时提升为平台管理员的账号（须已注册）
+}
+
 func GetConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
@@ -58,6 +96,27 @@ func GetConfig() *Config {
 			DefaultExpiration: 5 * time.Minute,
 			CleanupInterval:   10 * time.Minute,
 		},
+		Play: PlayConfig{
+			ConfirmWindow: getEnvDuration("PLAY_CONFIRM_WINDOW", 24*time.Hour),
+			MaxActive:     getEnvDuration("PLAY_MAX_ACTIVE", 12*time.Hour),
+			StaleAction:   getEnv("PLAY_STALE_ACTION", "cancel"),
+			AmendWindow:   getEnvDuration("PLAY_AMEND_WINDOW", 72*time.Hour),
+			IdleTimeout:   getEnvDuration("PLAY_IDLE_TIMEOUT", 30*time.Minute),
+		},
+		Schedule: ScheduleConfig{
+			Interval: getEnvDuration("SCHEDULE_INTERVAL", time.Minute),
+		},
+		Rating: RatingConfig{
+			PriorWeight:  getEnvFloat("RATING_PRIOR_WEIGHT", 10),
+			HalfLife:     getEnvDuration("RATING_HALF_LIFE", 180*24*time.Hour),
+			RefreshAfter: getEnvDuration("RATING_REFRESH_AFTER", 24*time.Hour),
+		},
+		Review: ReviewConfig{
+			EditWindow: getEnvDuration("REVIEW_EDIT_WINDOW", 7*24*time.Hour),
+		},
+		Admin: AdminConfig{
+			Usernames: getEnvList("ADMIN_USERNAMES"),
+		},
 	}
 }
 
@@ -66,4 +125,35 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// getEnvDuration 读取 time.ParseDuration 格式的环境变量（如 "24h"、"90m"），非法值回退默认值
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// getEnvFloat 读取浮点数环境变量，非法值回退默认值
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList 读取逗号分隔的环境变量，忽略空项
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 
 	"companion-platform-backend/models"
 
@@ -41,24 +43,185 @@ func InitDatabase(config *Config) error {
 		return fmt.Errorf("failed to migrate database: %v", err)
 	}
 
+	// 初始化基础数据
+	if err := SeedGameCatalog(); err != nil {
+		return fmt.Errorf("failed to seed game catalog: %v", err)
+	}
+	if err := SeedReviewTags(); err != nil {
+		return fmt.Errorf("failed to seed review tags: %v", err)
+	}
+	if err := SeedAdmins(config.Admin.Usernames); err != nil {
+		return fmt.Errorf("failed to seed admins: %v", err)
+	}
+
 	log.Println("Database connected and migrated successfully")
 	return nil
 }
 
 // AutoMigrate 自动迁移数据库表
 func AutoMigrate() error {
+	if err := dedupeReviewPlayRecords(); err != nil {
+		return fmt.Errorf("dedupe review play records: %v", err)
+	}
 	return DB.AutoMigrate(
 		&models.User{},
 		&models.Studio{},
 		&models.ProviderStudioRelation{},
+		&models.RelationStatusLog{},
+		&models.StudioInvite{},
+		&models.StudioStaff{},
+		&models.StudioTransfer{},
+		&models.StudioApplicationPolicy{},
 		&models.Balance{},
 		&models.BalanceTransaction{},
 		&models.PlayRecord{},
+		&models.Subscription{},
+		&models.CancellationPolicy{},
+		&models.PlayRecordRevision{},
+		&models.PlayRecordEvent{},
+		&models.MatchOutcome{},
 		&models.Review{},
+		&models.ReviewReply{},
+		&models.ReviewRevision{},
+		&models.ReviewReport{},
+		&models.ReviewHelpfulVote{},
+		&models.ReviewModeration{},
+		&models.ReviewTag{},
+		&models.RatingScore{},
+		&models.Notification{},
+		&models.Game{},
+		&models.GameMode{},
+		&models.GameAlias{},
+		&models.Tip{},
 	)
 }
 
+// dedupeReviewPlayRecords 为 reviews.play_record_id 唯一索引做准备：早期版本允许同一局有多条评价，
+// 每局保留最早的一条，其余解除关联（play_record_id 置空、取消已验证），否则唯一索引无法建立
+func dedupeReviewPlayRecords() error {
+	if !DB.Migrator().HasTable(&models.Review{}) || !DB.Migrator().HasColumn(&models.Review{}, "PlayRecordID") {
+		return nil
+	}
+	res := DB.Exec(`UPDATE reviews SET play_record_id = NULL, verified = ?
+		WHERE play_record_id IS NOT NULL AND id NOT IN (
+			SELECT keep_id FROM (
+				SELECT MIN(id) AS keep_id FROM reviews WHERE play_record_id IS NOT NULL GROUP BY play_record_id
+			) AS kept
+		)`, false)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("Detached %d duplicate reviews from their play records", res.RowsAffected)
+	}
+	return nil
+}
+
+// defaultGames 初始游戏目录：名称 → 模式 / 别名
+var defaultGames = []struct {
+	Name    string
+	Modes   []string
+	Aliases []string
+}{
+	{"王者荣耀", []string{"匹配", "排位赛", "巅峰赛"}, []string{"王者", "wzry"}},
+	{"和平精英", []string{"经典", "团竞"}, []string{"吃鸡", "hpjy"}},
+	{"英雄联盟", []string{"匹配", "单双排", "灵活组排", "大乱斗"}, []string{"lol", "撸啊撸"}},
+	{"无畏契约", []string{"匹配", "排位"}, []string{"瓦", "valorant"}},
+	{"永劫无间", []string{"单排", "三排"}, []string{"永劫"}},
+}
+
+// SeedGameCatalog 游戏目录为空时写入初始数据（已有数据则跳过）
+func SeedGameCatalog() error {
+	var count int64
+	if err := DB.Model(&models.Game{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for i, g := range defaultGames {
+			game := models.Game{Name: g.Name, SortOrder: i, IsActive: true}
+			if err := tx.Create(&game).Error; err != nil {
+				return err
+			}
+			for j, m := range g.Modes {
+				if err := tx.Create(&models.GameMode{GameID: game.ID, Name: m, SortOrder: j}).Error; err != nil {
+					return err
+				}
+			}
+			for _, a := range g.Aliases {
+				if err := tx.Create(&models.GameAlias{GameID: game.ID, Alias: strings.ToLower(a)}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// defaultReviewTags 初始评价标签词表
+var defaultReviewTags = []struct {
+	Polarity models.TagPolarity
+	Names    []string
+}{
+	{models.TagPositive, []string{"技术好", "声音好听", "准时", "耐心", "幽默风趣", "带飞"}},
+	{models.TagNegative, []string{"迟到", "态度差", "技术一般", "挂机", "沟通少"}},
+}
+
+// SeedReviewTags 标签词表为空时写入初始标签（已有数据则跳过）
+func SeedReviewTags() error {
+	var count int64
+	if err := DB.Model(&models.ReviewTag{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, group := range defaultReviewTags {
+			for i, name := range group.Names {
+				tag := models.ReviewTag{Name: name, Polarity: group.Polarity, SortOrder: i, IsActive: true}
+				if err := tx.Create(&tag).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// SeedAdmins 将指定账号提升为平台管理员（账号须先正常注册，重新登录后生效）；不存在的账号记日志后跳过。
+// 只提升玩家账号：服务者、工作室账号提升后会失去原角色的全部功能，拒绝并记日志
+func SeedAdmins(usernames []string) error {
+	for _, name := range usernames {
+		var user models.User
+		if err := DB.Where("username = ?", name).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("admin account %q not found, skipped", name)
+				continue
+			}
+			return err
+		}
+		switch user.Role {
+		case models.RoleAdmin:
+			continue
+		case models.RolePlayer:
+		default:
+			log.Printf("WARNING: admin account %q has role %q, refusing to promote; register a separate player account for ADMIN_USERNAMES", name, user.Role)
+			continue
+		}
+		if err := DB.Model(&models.User{}).Where("id = ? AND role = ?", user.ID, models.RolePlayer).
+			Update("role", models.RoleAdmin).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetDB 获取数据库实例
 func GetDB() *gorm.DB {
 	return DB
-}
\ No newline at end of file
+}
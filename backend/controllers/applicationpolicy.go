@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/models"
+	"companion-platform-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ApplicationPolicyController struct{}
+
+// UpsertApplicationPolicyRequest 设置加入申请策略
+type UpsertApplicationPolicyRequest struct {
+	Terms                string   `json:"terms"`
+	RequiredFields       []string `json:"required_fields"`
+	MinRating            float64  `json:"min_rating" binding:"min=0,max=5"`
+	MinCompletedSessions int      `json:"min_completed_sessions" binding:"min=0"`
+	AutoApprove          bool     `json:"auto_approve"`
+	AutoReject           bool     `json:"auto_reject"`
+}
+
+// studioApplicationPolicy 工作室的申请策略，未设置返回 nil
+func studioApplicationPolicy(db *gorm.DB, studioID uint) *models.StudioApplicationPolicy {
+	var policy models.StudioApplicationPolicy
+	if db.Where("studio_id = ?", studioID).First(&policy).Error != nil {
+		return nil
+	}
+	return &policy
+}
+
+// missingApplicationFields 策略要求但申请未填写（或为空白）的资料项
+func missingApplicationFields(policy *models.StudioApplicationPolicy, fields map[string]string) []string {
+	var missing []string
+	if policy == nil {
+		return missing
+	}
+	for _, name := range policy.RequiredFields {
+		if strings.TrimSpace(fields[name]) == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// evaluateApplication 按策略门槛评估服务者，返回触发的规则与判定依据；无策略或不触发任何规则时返回空。
+// 未满足门槛：auto_reject 时自动拒绝，否则转人工审核；满足全部门槛且 auto_approve 时自动批准
+func evaluateApplication(db *gorm.DB, policy *models.StudioApplicationPolicy, providerID uint) (models.ApplicationRule, string) {
+	if policy == nil {
+		return "", ""
+	}
+
+	var failures, facts []string
+	if policy.MinRating > 0 {
+		var avg *float64
+		db.Model(&models.Review{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", models.ReviewTargetProvider, providerID, models.ReviewStatusVisible).
+			Select("AVG(rating)").Scan(&avg)
+		rating := 0.0
+		if avg != nil {
+			rating = *avg
+		}
+		facts = append(facts, fmt.Sprintf("评分 %.1f", rating))
+		if rating < policy.MinRating {
+			failures = append(failures, fmt.Sprintf("评分 %.1f 低于要求 %.1f", rating, policy.MinRating))
+		}
+	}
+	if policy.MinCompletedSessions > 0 {
+		var completed int64
+		db.Model(&models.PlayRecord{}).
+			Where("provider_id = ? AND status = ?", providerID, models.PlayStatusCompleted).Count(&completed)
+		facts = append(facts, fmt.Sprintf("已完成 %d 局", completed))
+		if completed < int64(policy.MinCompletedSessions) {
+			failures = append(failures, fmt.Sprintf("已完成 %d 局，少于要求 %d 局", completed, policy.MinCompletedSessions))
+		}
+	}
+
+	if len(failures) > 0 {
+		detail := "未满足申请要求：" + strings.Join(failures, "；")
+		if policy.AutoReject {
+			return models.RuleAutoReject, detail
+		}
+		return models.RuleManualReview, detail
+	}
+	if policy.AutoApprove {
+		detail := "满足全部申请要求"
+		if len(facts) > 0 {
+			detail += "（" + strings.Join(facts, "，") + "）"
+		}
+		return models.RuleAutoApprove, detail
+	}
+	return "", ""
+}
+
+// Get 公开查询工作室的加入申请策略（未设置时返回 null）
+func (ac *ApplicationPolicyController) Get(c *gin.Context) {
+	studioID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid studio ID")
+		return
+	}
+
+	utils.Success(c, studioApplicationPolicy(config.GetDB(), studioID))
+}
+
+// Upsert 所有者或有编辑权限的员工设置加入申请策略
+func (ac *ApplicationPolicyController) Upsert(c *gin.Context) {
+	userID, studio, ok := authorizeStudio(c, models.PermStudioEdit)
+	if !ok {
+		return
+	}
+
+	var req UpsertApplicationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if req.AutoApprove && req.MinRating == 0 && req.MinCompletedSessions == 0 {
+		utils.BadRequest(c, "自动批准须至少设置一项门槛（最低评分或最少完成局数）")
+		return
+	}
+
+	fields := []string{}
+	seen := map[string]bool{}
+	for _, f := range req.RequiredFields {
+		f = strings.TrimSpace(f)
+		if f != "" && !seen[f] {
+			seen[f] = true
+			fields = append(fields, f)
+		}
+	}
+
+	db := config.GetDB()
+	var policy models.StudioApplicationPolicy
+	err := db.Where("studio_id = ?", studio.ID).First(&policy).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.InternalServerError(c, "Failed to load application policy")
+		return
+	}
+	policy.StudioID = studio.ID
+	policy.Terms = req.Terms
+	policy.RequiredFields = fields
+	policy.MinRating = req.MinRating
+	policy.MinCompletedSessions = req.MinCompletedSessions
+	policy.AutoApprove = req.AutoApprove
+	policy.AutoReject = req.AutoReject
+	policy.UpdatedBy = userID
+	if err := db.Save(&policy).Error; err != nil {
+		utils.InternalServerError(c, "Failed to save application policy")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "申请策略已保存", policy)
+}
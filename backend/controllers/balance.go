@@ -136,9 +136,9 @@ func (bc *BalanceController) operate(c *gin.Context, txType models.TransactionTy
 			return
 		}
 	} else { // RoleStudio
-		var studio models.Studio
-		if err := db.Where("owner_id = ?", userID).First(&studio).Error; err != nil {
-			utils.NotFound(c, "未找到你的工作室")
+		// 多工作室账号通过 studio_id 或 X-Studio-ID 选择工作室
+		studio, ok := selectStudio(c, db, userID, models.PermStudioBalance, req.StudioID)
+		if !ok {
 			return
 		}
 		// 校验该服务者已通过审批加入本工作室
@@ -169,6 +169,10 @@ func (bc *BalanceController) operate(c *gin.Context, txType models.TransactionTy
 	})
 
 	if txErr != nil {
+		if errors.Is(txErr, errStudioInactive) {
+			utils.BadRequest(c, txErr.Error())
+			return
+		}
 		if errors.Is(txErr, errInsufficientBalance) {
 			utils.BadRequest(c, "余额不足，无法扣费")
 			return
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/middleware"
+	"companion-platform-backend/models"
+	"companion-platform-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type CancellationPolicyController struct{}
+
+// UpsertCancellationPolicyRequest 设置取消策略
+type UpsertCancellationPolicyRequest struct {
+	FreeWindowMinutes int             `json:"free_window_minutes" binding:"min=0"`
+	LateFee           decimal.Decimal `json:"late_fee"`
+	NoShowFee         decimal.Decimal `json:"no_show_fee"`
+	Notes             string          `json:"notes"`
+}
+
+// CancelPlayRecordRequest 取消一局陪玩（请求体可省略）
+type CancelPlayRecordRequest struct {
+	NoShow bool   `json:"no_show"` // 仅服务者：登记玩家爽约（预约开始后才可登记）
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+var errNoShowTooEarly = errors.New("预约开始前不能登记爽约")
+
+// effectivePolicy 记录适用的取消策略：工作室记录优先工作室策略，其次服务者策略；都没有返回 nil
+func effectivePolicy(db *gorm.DB, providerID, studioID uint) *models.CancellationPolicy {
+	var policy models.CancellationPolicy
+	if studioID != 0 && db.Where("owner_type = ? AND owner_id = ?", models.PolicyOwnerStudio, studioID).
+		First(&policy).Error == nil {
+		return &policy
+	}
+	if db.Where("owner_type = ? AND owner_id = ?", models.PolicyOwnerProvider, providerID).
+		First(&policy).Error == nil {
+		return &policy
+	}
+	return nil
+}
+
+// evaluateCancel 按策略判定本次取消适用的规则与费用。
+// 以预约时间（无则开始时间）为基准：玩家在免费窗口前取消已预约场次免费，否则收迟取消费；
+// 服务者取消免费，登记爽约时收爽约费。
+func evaluateCancel(policy *models.CancellationPolicy, record *models.PlayRecord, byPlayer, noShow bool, now time.Time) (models.CancelRule, decimal.Decimal, error) {
+	ref := record.StartTime
+	if record.ScheduledAt != nil {
+		ref = *record.ScheduledAt
+	}
+	if !byPlayer {
+		if !noShow {
+			return models.CancelRuleProvider, decimal.Zero, nil
+		}
+		if now.Before(ref) {
+			return "", decimal.Zero, errNoShowTooEarly
+		}
+		if policy == nil {
+			return models.CancelRuleNoShow, decimal.Zero, nil
+		}
+		return models.CancelRuleNoShow, policy.NoShowFee, nil
+	}
+	if policy == nil {
+		return models.CancelRuleFree, decimal.Zero, nil
+	}
+	freeUntil := ref.Add(-time.Duration(policy.FreeWindowMinutes) * time.Minute)
+	if record.Status == models.PlayStatusBooked && !now.After(freeUntil) {
+		return models.CancelRuleFree, decimal.Zero, nil
+	}
+	return models.CancelRuleLate, policy.LateFee, nil
+}
+
+// cancelRecordTx 在事务内取消记录：条件更新状态并落取消快照；订阅场次先退还该场预付，再按规则收取费用。
+// 余额不足时按可用余额扣除，差额记为欠费，取消照常完成
+func cancelRecordTx(tx *gorm.DB, record *models.PlayRecord, policy *models.CancellationPolicy,
+	rule models.CancelRule, fee decimal.Decimal, operatorID uint, reason string, now time.Time) error {
+
+	updates := map[string]interface{}{
+		"status":        models.PlayStatusCancelled,
+		"end_time":      &now,
+		"cancelled_by":  operatorID,
+		"cancel_rule":   rule,
+		"cancel_fee":    fee,
+		"cancel_reason": reason,
+	}
+	if policy != nil {
+		updates["cancel_policy_id"] = policy.ID
+		updates["cancel_free_window_minutes"] = policy.FreeWindowMinutes
+		updates["cancel_late_fee"] = policy.LateFee
+		updates["cancel_no_show_fee"] = policy.NoShowFee
+	}
+	res := tx.Model(&models.PlayRecord{}).
+		Where("id = ? AND status = ?", record.ID, record.Status).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errRecordState
+	}
+
+	if record.SubscriptionID != nil {
+		if err := dropSubscriptionSessionTx(tx, *record.SubscriptionID, operatorID); err != nil {
+			return err
+		}
+	}
+	if !fee.GreaterThan(decimal.Zero) {
+		return nil
+	}
+
+	// 只扣可用余额（冻结额是已扣出的申诉款，不在 amount 内），不足部分记为欠费
+	charge := fee
+	var balance models.Balance
+	err := lockForUpdate(tx).
+		Where("player_id = ? AND provider_id = ? AND studio_id = ? AND type = ?",
+			record.PlayerID, record.ProviderID, record.StudioID, record.SettleType).
+		First(&balance).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		charge = decimal.Zero
+	case err != nil:
+		return err
+	default:
+		if balance.Amount.LessThan(charge) {
+			charge = balance.Amount
+		}
+	}
+	if owed := fee.Sub(charge); owed.GreaterThan(decimal.Zero) {
+		if err := tx.Model(&models.PlayRecord{}).Where("id = ?", record.ID).
+			Update("cancel_fee_owed", owed).Error; err != nil {
+			return err
+		}
+	}
+	if charge.IsZero() {
+		return nil
+	}
+
+	label := map[models.CancelRule]string{
+		models.CancelRuleLate:   "迟取消费",
+		models.CancelRuleNoShow: "爽约费",
+	}[rule]
+	_, err = adjustBalanceTx(tx, record.PlayerID, record.ProviderID, record.StudioID, record.SettleType,
+		charge.Neg(), models.TransactionTypeCancelFee, operatorID, label+" · "+recordDesc(record))
+	return err
+}
+
+// GetMine 服务者查看自己的取消策略（未设置时返回 null）
+func (cc *CancellationPolicyController) GetMine(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+	cc.get(c, models.PolicyOwnerProvider, userID)
+}
+
+// UpsertMine 服务者设置自己的取消策略
+func (cc *CancellationPolicyController) UpsertMine(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+	cc.upsert(c, models.PolicyOwnerProvider, userID)
+}
+
+// GetStudio 工作室所有者或员工查看工作室取消策略
+func (cc *CancellationPolicyController) GetStudio(c *gin.Context) {
+	_, studio, ok := authorizeStudio(c, models.PermStudioView)
+	if !ok {
+		return
+	}
+	cc.get(c, models.PolicyOwnerStudio, studio.ID)
+}
+
+// UpsertStudio 工作室所有者或有编辑权限的员工设置工作室取消策略（对该工作室的记录优先于服务者策略）
+func (cc *CancellationPolicyController) UpsertStudio(c *gin.Context) {
+	_, studio, ok := authorizeStudio(c, models.PermStudioEdit)
+	if !ok {
+		return
+	}
+	cc.upsert(c, models.PolicyOwnerStudio, studio.ID)
+}
+
+// Effective 公开查询某服务者（可带 studio_id）实际适用的取消策略
+func (cc *CancellationPolicyController) Effective(c *gin.Context) {
+	providerID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid provider ID")
+		return
+	}
+	studioID, _ := parseUintParam(c.DefaultQuery("studio_id", "0"))
+
+	utils.Success(c, effectivePolicy(config.GetDB(), providerID, studioID))
+}
+
+func (cc *CancellationPolicyController) get(c *gin.Context, ownerType models.PolicyOwnerType, ownerID uint) {
+	var policy models.CancellationPolicy
+	if err := config.GetDB().Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+		First(&policy).Error; err != nil {
+		utils.Success(c, nil)
+		return
+	}
+	utils.Success(c, policy)
+}
+
+func (cc *CancellationPolicyController) upsert(c *gin.Context, ownerType models.PolicyOwnerType, ownerID uint) {
+	var req UpsertCancellationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if req.LateFee.IsNegative() || req.NoShowFee.IsNegative() {
+		utils.BadRequest(c, "费用不能为负")
+		return
+	}
+
+	db := config.GetDB()
+	var policy models.CancellationPolicy
+	err := db.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).First(&policy).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.InternalServerError(c, "Failed to load cancellation policy")
+		return
+	}
+	policy.OwnerType = ownerType
+	policy.OwnerID = ownerID
+	policy.FreeWindowMinutes = req.FreeWindowMinutes
+	policy.LateFee = req.LateFee
+	policy.NoShowFee = req.NoShowFee
+	policy.Notes = req.Notes
+	if err := db.Save(&policy).Error; err != nil {
+		utils.InternalServerError(c, "Failed to save cancellation policy")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "取消策略已保存", policy)
+}
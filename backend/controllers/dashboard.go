@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 type DashboardController struct{}
@@ -57,7 +58,15 @@ func (dc *DashboardController) PlayerDashboard(c *gin.Context) {
 	// 进行中的陪玩
 	var ongoing []models.PlayRecord
 	db.Where("player_id = ? AND status = ?", userID, models.PlayStatusActive).
-		Preload("Provider").Order("start_time DESC").Find(&ongoing)
+		Preload("Provider").
+		Preload("Events", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at ASC, id ASC") }).
+		Order("start_time DESC").Find(&ongoing)
+
+	// 待确认的已完成陪玩（确认窗口内）
+	var pendingConfirm []models.PlayRecord
+	db.Where("player_id = ? AND status = ? AND confirm_status = ?",
+		userID, models.PlayStatusCompleted, models.ConfirmStatusPending).
+		Preload("Provider").Order("confirm_deadline ASC").Find(&pendingConfirm)
 
 	utils.Success(c, gin.H{
 		"money_total":         totals[models.BalanceTypeMoney],
@@ -66,6 +75,7 @@ func (dc *DashboardController) PlayerDashboard(c *gin.Context) {
 		"provider_count":      providerCount,
 		"recent_transactions": recent,
 		"ongoing_records":     ongoing,
+		"pending_confirm":     pendingConfirm,
 	})
 }
 
@@ -155,12 +165,21 @@ func (dc *DashboardController) ProviderDashboard(c *gin.Context) {
 	// 近 7 天每日陪玩局数
 	weekly := dc.weeklyPlayCounts(userID)
 
+	// 打赏单列：全额归服务者，不计入余额收益
+	tips := tipSummary(db, userID)
+
+	// 待回复的评价
+	unanswered, unansweredCount := unansweredReviews(db, models.ReviewTargetProvider, userID, 10)
+
 	utils.Success(c, gin.H{
 		"earnings":           earnings,
 		"player_count":       int64(len(order)),
 		"active_players":     activePlayers,
 		"weekly_play_counts": weekly,
 		"todos":              todos,
+		"tips":               tips,
+		"unanswered_reviews": unanswered,
+		"unanswered_count":   unansweredCount,
 	})
 }
 
@@ -190,62 +209,128 @@ func (dc *DashboardController) weeklyPlayCounts(providerID uint) []dayCount {
 	return out
 }
 
-// StudioDashboard 工作室控制台聚合数据
-func (dc *DashboardController) StudioDashboard(c *gin.Context) {
-	userID, err := middleware.GetCurrentUserID(c)
-	if err != nil {
-		utils.Unauthorized(c, "User not found")
-		return
-	}
-
-	db := config.GetDB()
-
-	var studio models.Studio
-	if err := db.Where("owner_id = ?", userID).First(&studio).Error; err != nil {
-		utils.NotFound(c, "未找到你的工作室，请先创建")
-		return
-	}
+// StudioStats 单个工作室的经营指标
+type StudioStats struct {
+	MemberCount       int64           `json:"member_count"`
+	ServedPlayerCount int64           `json:"served_player_count"`
+	MonthlyFlow       decimal.Decimal `json:"monthly_flow"`
+	AverageRating     float64         `json:"average_rating"`
+	PendingCount      int64           `json:"pending_count"`
+	UnansweredCount   int64           `json:"unanswered_count"`
+}
 
-	var memberCount int64
+// studioStats 计算工作室的成员数、服务玩家数、本月充值流水、评分、待审批与待回复数量
+func studioStats(db *gorm.DB, studioID uint, now time.Time) StudioStats {
+	stats := StudioStats{MonthlyFlow: decimal.Zero}
 	db.Model(&models.ProviderStudioRelation{}).
-		Where("studio_id = ? AND status = ?", studio.ID, models.StatusApproved).Count(&memberCount)
+		Where("studio_id = ? AND status = ?", studioID, models.StatusApproved).Count(&stats.MemberCount)
 
-	var servedPlayers int64
-	db.Model(&models.Balance{}).Where("studio_id = ?", studio.ID).Distinct("player_id").Count(&servedPlayers)
+	db.Model(&models.Balance{}).Where("studio_id = ?", studioID).Distinct("player_id").Count(&stats.ServedPlayerCount)
 
-	now := time.Now()
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	var monthlyFlow decimal.Decimal
 	db.Model(&models.BalanceTransaction{}).
 		Joins("JOIN balances ON balances.id = balance_transactions.balance_id").
 		Where("balances.studio_id = ? AND balance_transactions.type = ? AND balance_transactions.created_at >= ?",
-			studio.ID, models.TransactionTypeRecharge, startOfMonth).
-		Select("COALESCE(SUM(balance_transactions.amount),0)").Scan(&monthlyFlow)
+			studioID, models.TransactionTypeRecharge, startOfMonth).
+		Select("COALESCE(SUM(balance_transactions.amount),0)").Scan(&stats.MonthlyFlow)
 
 	var avgRating *float64
 	db.Model(&models.Review{}).
-		Where("target_type = ? AND target_id = ?", models.ReviewTargetStudio, studio.ID).
+		Where("target_type = ? AND target_id = ? AND status = ?", models.ReviewTargetStudio, studioID, models.ReviewStatusVisible).
 		Select("AVG(rating)").Scan(&avgRating)
-	rating := 0.0
 	if avgRating != nil {
-		rating = *avgRating
+		stats.AverageRating = *avgRating
 	}
 
-	var pendingCount int64
 	db.Model(&models.ProviderStudioRelation{}).
-		Where("studio_id = ? AND status = ?", studio.ID, models.StatusPending).Count(&pendingCount)
+		Where("studio_id = ? AND status = ?", studioID, models.StatusPending).Count(&stats.PendingCount)
+	_, stats.UnansweredCount = unansweredReviews(db, models.ReviewTargetStudio, studioID, 0)
+	return stats
+}
+
+// StudioDashboard 工作室控制台聚合数据（多工作室账号通过 /studio/:id/dashboard 或 X-Studio-ID 选择工作室）
+func (dc *DashboardController) StudioDashboard(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	db := config.GetDB()
+
+	studio, ok := selectStudio(c, db, userID, models.PermStudioView, 0)
+	if !ok {
+		return
+	}
+
+	stats := studioStats(db, studio.ID, time.Now())
 
 	var pending []models.ProviderStudioRelation
 	db.Where("studio_id = ? AND status = ?", studio.ID, models.StatusPending).
 		Preload("Provider").Order("applied_at DESC").Limit(10).Find(&pending)
 
+	unanswered, _ := unansweredReviews(db, models.ReviewTargetStudio, studio.ID, 10)
+
 	utils.Success(c, gin.H{
 		"studio":               studio,
-		"member_count":         memberCount,
-		"served_player_count":  servedPlayers,
-		"monthly_flow":         monthlyFlow,
-		"average_rating":       rating,
-		"pending_count":        pendingCount,
+		"member_count":         stats.MemberCount,
+		"served_player_count":  stats.ServedPlayerCount,
+		"monthly_flow":         stats.MonthlyFlow,
+		"average_rating":       stats.AverageRating,
+		"pending_count":        stats.PendingCount,
 		"pending_applications": pending,
+		"unanswered_reviews":   unanswered,
+		"unanswered_count":     stats.UnansweredCount,
+	})
+}
+
+// StudioOverviewItem 汇总控制台中的单个工作室
+type StudioOverviewItem struct {
+	StudioAccess
+	Stats StudioStats `json:"stats"`
+}
+
+// StudioOverview 汇总控制台：当前用户拥有或任职的全部工作室的指标与合计（服务玩家数跨工作室去重，评分按全部评价计算）
+func (dc *DashboardController) StudioOverview(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	db := config.GetDB()
+	studios, err := accessibleStudios(db, userID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to get studios")
+		return
+	}
+
+	now := time.Now()
+	items := make([]StudioOverviewItem, 0, len(studios))
+	totals := StudioStats{MonthlyFlow: decimal.Zero}
+	ids := make([]uint, 0, len(studios))
+	for _, sa := range studios {
+		stats := studioStats(db, sa.Studio.ID, now)
+		items = append(items, StudioOverviewItem{StudioAccess: sa, Stats: stats})
+		ids = append(ids, sa.Studio.ID)
+		totals.MemberCount += stats.MemberCount
+		totals.MonthlyFlow = totals.MonthlyFlow.Add(stats.MonthlyFlow)
+		totals.PendingCount += stats.PendingCount
+		totals.UnansweredCount += stats.UnansweredCount
+	}
+	if len(ids) > 0 {
+		db.Model(&models.Balance{}).Where("studio_id IN ?", ids).Distinct("player_id").Count(&totals.ServedPlayerCount)
+		var avgRating *float64
+		db.Model(&models.Review{}).
+			Where("target_type = ? AND target_id IN ? AND status = ?", models.ReviewTargetStudio, ids, models.ReviewStatusVisible).
+			Select("AVG(rating)").Scan(&avgRating)
+		if avgRating != nil {
+			totals.AverageRating = *avgRating
+		}
+	}
+
+	utils.Success(c, gin.H{
+		"studios": items,
+		"totals":  totals,
 	})
 }
@@ -0,0 +1,443 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/models"
+	"companion-platform-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type GameController struct{}
+
+var (
+	errGameNotInCatalog = errors.New("游戏不在目录中")
+	errModeNotInCatalog = errors.New("游戏模式不在目录中")
+	errAliasTaken       = errors.New("别名已被占用")
+)
+
+// CreateGameRequest 新增目录游戏
+type CreateGameRequest struct {
+	Name      string   `json:"name" binding:"required,max=100"`
+	Icon      string   `json:"icon"`
+	SortOrder int      `json:"sort_order"`
+	Modes     []string `json:"modes" binding:"dive,max=50"`
+	Aliases   []string `json:"aliases"`
+}
+
+// UpdateGameRequest 修改目录游戏
+type UpdateGameRequest struct {
+	Name      string `json:"name" binding:"required,max=100"`
+	Icon      string `json:"icon"`
+	SortOrder int    `json:"sort_order"`
+	IsActive  *bool  `json:"is_active"`
+}
+
+// AddGameModeRequest 为游戏新增模式
+type AddGameModeRequest struct {
+	Name      string   `json:"name" binding:"required,max=50"`
+	Icon      string   `json:"icon"`
+	SortOrder int      `json:"sort_order"`
+	Aliases   []string `json:"aliases"`
+}
+
+// AddGameAliasRequest 为游戏（mode_id=0）或其模式新增别名
+type AddGameAliasRequest struct {
+	Alias  string `json:"alias" binding:"required,max=100"`
+	ModeID uint   `json:"mode_id"`
+}
+
+// normalizeGameText 目录匹配用的规范化：去首尾空白并转小写
+func normalizeGameText(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// resolveGame 按 ID 或名称/别名在目录中解析游戏与模式（modeID/modeName 均为空时不解析模式）。
+// 只匹配启用中的游戏。
+func resolveGame(db *gorm.DB, gameID, modeID uint, gameName, modeName string) (*models.Game, *models.GameMode, error) {
+	return lookupGame(db, true, gameID, modeID, gameName, modeName)
+}
+
+// resolveHistoryGame 同 resolveGame，但也匹配已停用的游戏，用于筛选与归档历史记录
+func resolveHistoryGame(db *gorm.DB, gameID, modeID uint, gameName, modeName string) (*models.Game, *models.GameMode, error) {
+	return lookupGame(db, false, gameID, modeID, gameName, modeName)
+}
+
+// lookupGame resolveGame / resolveHistoryGame 的实现，activeOnly 为 true 时只匹配启用中的游戏
+func lookupGame(db *gorm.DB, activeOnly bool, gameID, modeID uint, gameName, modeName string) (*models.Game, *models.GameMode, error) {
+	games := func() *gorm.DB {
+		if activeOnly {
+			return db.Where("games.is_active = ?", true)
+		}
+		return db
+	}
+
+	var game models.Game
+	switch {
+	case gameID != 0:
+		if err := games().Where("games.id = ?", gameID).First(&game).Error; err != nil {
+			return nil, nil, errGameNotInCatalog
+		}
+	case normalizeGameText(gameName) != "":
+		name := normalizeGameText(gameName)
+		err := games().Where("LOWER(games.name) = ?", name).First(&game).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = games().Joins("JOIN game_aliases ON game_aliases.game_id = games.id").
+				Where("game_aliases.mode_id = 0 AND game_aliases.alias = ?", name).
+				First(&game).Error
+		}
+		if err != nil {
+			return nil, nil, errGameNotInCatalog
+		}
+	default:
+		return nil, nil, errGameNotInCatalog
+	}
+
+	if modeID == 0 && normalizeGameText(modeName) == "" {
+		return &game, nil, nil
+	}
+
+	var mode models.GameMode
+	if modeID != 0 {
+		if err := db.Where("id = ? AND game_id = ?", modeID, game.ID).First(&mode).Error; err != nil {
+			return &game, nil, errModeNotInCatalog
+		}
+		return &game, &mode, nil
+	}
+	name := normalizeGameText(modeName)
+	err := db.Where("game_id = ? AND LOWER(name) = ?", game.ID, name).First(&mode).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = db.Joins("JOIN game_aliases ON game_aliases.mode_id = game_modes.id").
+			Where("game_modes.game_id = ? AND game_aliases.alias = ?", game.ID, name).
+			First(&mode).Error
+	}
+	if err != nil {
+		return &game, nil, errModeNotInCatalog
+	}
+	return &game, &mode, nil
+}
+
+// gameNameTaken 游戏名称是否与其他游戏（含已停用）的名称或游戏别名冲突，excludeID 为自身（新增时传 0）
+func gameNameTaken(db *gorm.DB, name string, excludeID uint) bool {
+	name = normalizeGameText(name)
+	var count int64
+	db.Model(&models.Game{}).Where("LOWER(name) = ? AND id <> ?", name, excludeID).Count(&count)
+	if count == 0 {
+		db.Model(&models.GameAlias{}).Where("alias = ? AND mode_id = 0 AND game_id <> ?", name, excludeID).Count(&count)
+	}
+	return count > 0
+}
+
+// addGameAliasTx 写入一条别名，校验游戏别名全局唯一、模式别名在同一游戏内唯一
+func addGameAliasTx(tx *gorm.DB, gameID, modeID uint, alias string) (*models.GameAlias, error) {
+	alias = normalizeGameText(alias)
+	if alias == "" {
+		return nil, errors.New("别名不能为空")
+	}
+
+	var count int64
+	q := tx.Model(&models.GameAlias{}).Where("alias = ? AND mode_id = ?", alias, modeID)
+	if modeID == 0 {
+		// 游戏别名不能与其他游戏的名称或别名冲突
+		var nameCount int64
+		tx.Model(&models.Game{}).Where("LOWER(name) = ? AND id <> ?", alias, gameID).Count(&nameCount)
+		if nameCount > 0 {
+			return nil, errAliasTaken
+		}
+	} else {
+		q = q.Where("game_id = ?", gameID)
+	}
+	if err := q.Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errAliasTaken
+	}
+
+	row := models.GameAlias{GameID: gameID, ModeID: modeID, Alias: alias}
+	if err := tx.Create(&row).Error; err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// List 游戏目录（公开，含模式与别名）
+func (gc *GameController) List(c *gin.Context) {
+	db := config.GetDB()
+	query := db.Model(&models.Game{})
+	if c.Query("all") != "1" {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var games []models.Game
+	if err := query.
+		Preload("Modes", func(tx *gorm.DB) *gorm.DB { return tx.Order("sort_order ASC, id ASC") }).
+		Preload("Aliases").
+		Order("sort_order ASC, id ASC").Find(&games).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get games")
+		return
+	}
+
+	utils.Success(c, games)
+}
+
+// CreateGame 新增目录游戏（管理员），可同时带上模式与别名
+func (gc *GameController) CreateGame(c *gin.Context) {
+	var req CreateGameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	if gameNameTaken(db, req.Name, 0) {
+		utils.BadRequest(c, "游戏名称与已有游戏或别名重复")
+		return
+	}
+
+	// 模式名称去首尾空白，按不区分大小写去重，避免撞上 (game_id, name) 唯一索引
+	var modes []string
+	seen := map[string]bool{}
+	for _, m := range req.Modes {
+		key := normalizeGameText(m)
+		if key == "" {
+			utils.BadRequest(c, "模式名称不能为空")
+			return
+		}
+		if !seen[key] {
+			seen[key] = true
+			modes = append(modes, strings.TrimSpace(m))
+		}
+	}
+
+	game := models.Game{Name: strings.TrimSpace(req.Name), Icon: req.Icon, SortOrder: req.SortOrder, IsActive: true}
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&game).Error; err != nil {
+			return err
+		}
+		for i, m := range modes {
+			if err := tx.Create(&models.GameMode{GameID: game.ID, Name: m, SortOrder: i}).Error; err != nil {
+				return err
+			}
+		}
+		for _, a := range req.Aliases {
+			if _, err := addGameAliasTx(tx, game.ID, 0, a); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if txErr != nil {
+		if errors.Is(txErr, errAliasTaken) {
+			utils.BadRequest(c, txErr.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to create game")
+		return
+	}
+
+	db.Preload("Modes").Preload("Aliases").First(&game, game.ID)
+	utils.SuccessWithMessage(c, "游戏已添加", game)
+}
+
+// UpdateGame 修改目录游戏（管理员）；停用后不再接受新记录，但历史记录保留
+func (gc *GameController) UpdateGame(c *gin.Context) {
+	gameID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid game ID")
+		return
+	}
+
+	var req UpdateGameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var game models.Game
+	if err := db.First(&game, gameID).Error; err != nil {
+		utils.NotFound(c, "游戏不存在")
+		return
+	}
+	// 改名不能与其他游戏的名称或别名冲突，否则名称归一会有歧义
+	if gameNameTaken(db, req.Name, game.ID) {
+		utils.BadRequest(c, "游戏名称与已有游戏或别名重复")
+		return
+	}
+
+	updates := map[string]interface{}{
+		"name":       strings.TrimSpace(req.Name),
+		"icon":       req.Icon,
+		"sort_order": req.SortOrder,
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if err := db.Model(&game).Updates(updates).Error; err != nil {
+		utils.InternalServerError(c, "Failed to update game")
+		return
+	}
+
+	db.Preload("Modes").Preload("Aliases").First(&game, gameID)
+	utils.SuccessWithMessage(c, "游戏已更新", game)
+}
+
+// AddMode 为游戏新增模式（管理员）
+func (gc *GameController) AddMode(c *gin.Context) {
+	gameID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid game ID")
+		return
+	}
+
+	var req AddGameModeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var game models.Game
+	if err := db.First(&game, gameID).Error; err != nil {
+		utils.NotFound(c, "游戏不存在")
+		return
+	}
+	if _, _, err := resolveGame(db, game.ID, 0, "", req.Name); err == nil {
+		utils.BadRequest(c, "模式名称与已有模式或别名重复")
+		return
+	}
+
+	mode := models.GameMode{GameID: game.ID, Name: strings.TrimSpace(req.Name), Icon: req.Icon, SortOrder: req.SortOrder}
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&mode).Error; err != nil {
+			return err
+		}
+		for _, a := range req.Aliases {
+			if _, err := addGameAliasTx(tx, game.ID, mode.ID, a); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if txErr != nil {
+		if errors.Is(txErr, errAliasTaken) {
+			utils.BadRequest(c, txErr.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to create mode")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "模式已添加", mode)
+}
+
+// AddAlias 为游戏或其模式新增别名（管理员）
+func (gc *GameController) AddAlias(c *gin.Context) {
+	gameID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid game ID")
+		return
+	}
+
+	var req AddGameAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var game models.Game
+	if err := db.First(&game, gameID).Error; err != nil {
+		utils.NotFound(c, "游戏不存在")
+		return
+	}
+	if req.ModeID != 0 {
+		var mode models.GameMode
+		if err := db.Where("id = ? AND game_id = ?", req.ModeID, game.ID).First(&mode).Error; err != nil {
+			utils.BadRequest(c, "模式不属于该游戏")
+			return
+		}
+	}
+
+	alias, err := addGameAliasTx(db, game.ID, req.ModeID, req.Alias)
+	if err != nil {
+		if errors.Is(err, errAliasTaken) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to add alias")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "别名已添加", alias)
+}
+
+// DeleteAlias 删除别名（管理员）
+func (gc *GameController) DeleteAlias(c *gin.Context) {
+	aliasID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid alias ID")
+		return
+	}
+
+	res := config.GetDB().Delete(&models.GameAlias{}, aliasID)
+	if res.Error != nil {
+		utils.InternalServerError(c, "Failed to delete alias")
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.NotFound(c, "别名不存在")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "别名已删除", nil)
+}
+
+// CatalogMappingReport 历史记录归入目录的结果
+type CatalogMappingReport struct {
+	Mapped         int64    `json:"mapped"`          // 成功归入目录的记录数
+	UnmatchedGames []string `json:"unmatched_games"` // 无法匹配的游戏名（记录保持 game_id = 0）
+	UnmatchedModes []string `json:"unmatched_modes"` // 游戏已匹配、但模式无法匹配的「游戏 · 模式」
+}
+
+// MapPlayRecordsToCatalog 把尚未归入目录（game_id = 0）的历史记录按名称/别名映射到目录，
+// 同时把 game_name / game_mode 改写为规范名称（已停用的游戏同样匹配）。模式无法匹配时只归入游戏、保留原模式文本。
+func MapPlayRecordsToCatalog(db *gorm.DB) (*CatalogMappingReport, error) {
+	var pairs []struct {
+		GameName string
+		GameMode string
+	}
+	if err := db.Model(&models.PlayRecord{}).Where("game_id = 0").
+		Distinct("game_name", "game_mode").Scan(&pairs).Error; err != nil {
+		return nil, err
+	}
+
+	report := &CatalogMappingReport{UnmatchedGames: []string{}, UnmatchedModes: []string{}}
+	for _, p := range pairs {
+		game, mode, err := resolveHistoryGame(db, 0, 0, p.GameName, p.GameMode)
+		if game == nil {
+			report.UnmatchedGames = append(report.UnmatchedGames, p.GameName)
+			continue
+		}
+		updates := map[string]interface{}{"game_id": game.ID, "game_name": game.Name}
+		if mode != nil {
+			updates["game_mode_id"] = mode.ID
+			updates["game_mode"] = mode.Name
+		} else if errors.Is(err, errModeNotInCatalog) {
+			report.UnmatchedModes = append(report.UnmatchedModes, game.Name+" · "+p.GameMode)
+		}
+		res := db.Model(&models.PlayRecord{}).
+			Where("game_id = 0 AND game_name = ? AND game_mode = ?", p.GameName, p.GameMode).
+			Updates(updates)
+		if res.Error != nil {
+			return report, res.Error
+		}
+		report.Mapped += res.RowsAffected
+	}
+	return report, nil
+}
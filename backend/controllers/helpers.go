@@ -15,6 +15,36 @@ import (
 // errInsufficientBalance 余额不足
 var errInsufficientBalance = errors.New("余额不足")
 
+// errForbidden 事务内鉴权失败，由调用方转换为 403
+var errForbidden = errors.New("无权操作")
+
+// errRecordState 条件更新未命中：记录状态已被并发修改
+var errRecordState = errors.New("该局已结束或已取消")
+
+// errDisputeState 条件更新未命中：确认 / 申诉状态已被并发修改
+var errDisputeState = errors.New("该局的确认或申诉状态已变化，请刷新后重试")
+
+// errFrozenAmount 冻结额将为负或超过申诉中的已扣费额：冻结 / 解冻与记录不一致，属账务错误
+var errFrozenAmount = errors.New("冻结额与申诉记录不一致")
+
+// errStudioInactive 工作室已停用，其名下余额暂停变动
+var errStudioInactive = errors.New("工作室已停用，暂停余额操作")
+
+// ensureStudioActiveTx 工作室名下的余额变动前校验工作室未停用（studioID 为 0 即个人余额，不校验）
+func ensureStudioActiveTx(tx *gorm.DB, studioID uint) error {
+	if studioID == 0 {
+		return nil
+	}
+	var studio models.Studio
+	if err := tx.Select("id", "is_active").First(&studio, studioID).Error; err != nil {
+		return err
+	}
+	if !studio.IsActive {
+		return errStudioInactive
+	}
+	return nil
+}
+
 // lockForUpdate 仅在 MySQL 上施加行级写锁（SELECT ... FOR UPDATE），
 // 防止「读-改-写」并发下的丢失更新；SQLite 写本身串行，无需加锁（也不支持该语法）。
 func lockForUpdate(tx *gorm.DB) *gorm.DB {
@@ -30,6 +60,10 @@ func lockForUpdate(tx *gorm.DB) *gorm.DB {
 func adjustBalanceTx(tx *gorm.DB, playerID, providerID, studioID uint, btype models.BalanceType,
 	delta decimal.Decimal, txType models.TransactionType, operatorID uint, desc string) (*models.Balance, error) {
 
+	if err := ensureStudioActiveTx(tx, studioID); err != nil {
+		return nil, err
+	}
+
 	var balance models.Balance
 	err := lockForUpdate(tx).
 		Where("player_id = ? AND provider_id = ? AND studio_id = ? AND type = ?",
@@ -82,6 +116,65 @@ func adjustBalanceTx(tx *gorm.DB, playerID, providerID, studioID uint, btype mod
 	return &balance, nil
 }
 
+// freezeBalanceTx 在事务 tx 内调整余额的冻结额：delta 为正表示冻结、为负表示解冻，并落一条冻结/解冻流水。
+// 解冻超过现有冻结额、或冻结超过申诉中的已扣费额时返回 errFrozenAmount，不做静默修正。
+// 冻结额是「已扣出、但去向待定」的部分（如申诉中的消费），不影响可用余额 amount；
+// 因此该类流水的 before/after 记录的是冻结额的变动前后。
+func freezeBalanceTx(tx *gorm.DB, playerID, providerID, studioID uint, btype models.BalanceType,
+	delta decimal.Decimal, operatorID uint, desc string) error {
+
+	if delta.IsPositive() {
+		if err := ensureStudioActiveTx(tx, studioID); err != nil {
+			return err
+		}
+	}
+
+	var balance models.Balance
+	if err := lockForUpdate(tx).
+		Where("player_id = ? AND provider_id = ? AND studio_id = ? AND type = ?",
+			playerID, providerID, studioID, btype).
+		First(&balance).Error; err != nil {
+		return err
+	}
+
+	before := balance.FrozenAmount
+	after := before.Add(delta)
+	if after.IsNegative() {
+		return errFrozenAmount
+	}
+	// 冻结额不得超过该余额下申诉中记录的已扣费合计（调用方须先将记录置为 disputed）
+	if delta.IsPositive() {
+		var disputed decimal.Decimal
+		if err := tx.Model(&models.PlayRecord{}).
+			Where("player_id = ? AND provider_id = ? AND studio_id = ? AND settle_type = ? AND confirm_status = ?",
+				playerID, providerID, studioID, btype, models.ConfirmStatusDisputed).
+			Select("COALESCE(SUM(charged_amount), 0)").Row().Scan(&disputed); err != nil {
+			return err
+		}
+		if after.GreaterThan(disputed) {
+			return errFrozenAmount
+		}
+	}
+	if err := tx.Model(&models.Balance{}).Where("id = ?", balance.ID).
+		Update("frozen_amount", after).Error; err != nil {
+		return err
+	}
+
+	txType := models.TransactionTypeFreeze
+	if delta.IsNegative() {
+		txType = models.TransactionTypeUnfreeze
+	}
+	return tx.Create(&models.BalanceTransaction{
+		BalanceID:    balance.ID,
+		Type:         txType,
+		Amount:       delta.Abs(),
+		BeforeAmount: before,
+		AfterAmount:  after,
+		Description:  desc,
+		OperatorID:   operatorID,
+	}).Error
+}
+
 // parseUintParam 解析路径/查询参数为 uint
 func parseUintParam(s string) (uint, error) {
 	v, err := strconv.ParseUint(s, 10, 32)
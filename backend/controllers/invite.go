@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/middleware"
+	"companion-platform-backend/models"
+	"companion-platform-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type StudioInviteController struct{}
+
+// CreateInviteRequest 生成邀请码
+type CreateInviteRequest struct {
+	Username       string `json:"username" binding:"max=50"` // 指定用户名（定向邀请），为空则任何服务者可用
+	MaxUses        int    `json:"max_uses" binding:"min=0"`  // 0 不限次数，1 一次性
+	ExpiresInHours int    `json:"expires_in_hours" binding:"min=0"`
+	Notes          string `json:"notes" binding:"max=500"`
+}
+
+// RedeemInviteRequest 兑换邀请码
+type RedeemInviteRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+var errInviteInvalid = errors.New("邀请码无效、已过期或已用完")
+
+var errInviteBlocked = errors.New("你已被该工作室移除或拒绝，需工作室定向邀请")
+
+// newInviteCode 生成 12 位随机邀请码
+func newInviteCode() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(buf)), nil
+}
+
+// Create 工作室所有者或审核员生成邀请码（可设定次数、有效期或指定用户名）
+func (ic *StudioInviteController) Create(c *gin.Context) {
+	userID, studio, ok := authorizeStudio(c, models.PermStudioApplications)
+	if !ok {
+		return
+	}
+
+	var req CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	username := strings.TrimSpace(req.Username)
+	if username != "" {
+		var target models.User
+		if err := db.Where("username = ? AND role = ?", username, models.RoleProvider).First(&target).Error; err != nil {
+			utils.BadRequest(c, "指定的服务者不存在")
+			return
+		}
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		utils.InternalServerError(c, "Failed to generate invite code")
+		return
+	}
+	invite := models.StudioInvite{
+		StudioID:  studio.ID,
+		Code:      code,
+		CreatedBy: userID,
+		Username:  username,
+		MaxUses:   req.MaxUses,
+		Notes:     req.Notes,
+	}
+	if req.ExpiresInHours > 0 {
+		expires := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		invite.ExpiresAt = &expires
+	}
+	if err := db.Create(&invite).Error; err != nil {
+		utils.InternalServerError(c, "Failed to create invite")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "邀请码已生成", invite)
+}
+
+// List 工作室的邀请码列表（含已撤销与已用完的）
+func (ic *StudioInviteController) List(c *gin.Context) {
+	_, studio, ok := authorizeStudio(c, models.PermStudioApplications)
+	if !ok {
+		return
+	}
+
+	var invites []models.StudioInvite
+	if err := config.GetDB().Where("studio_id = ?", studio.ID).
+		Order("created_at DESC, id DESC").Find(&invites).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get invites")
+		return
+	}
+
+	utils.Success(c, invites)
+}
+
+// Revoke 撤销邀请码（已加入的成员不受影响）
+func (ic *StudioInviteController) Revoke(c *gin.Context) {
+	_, studio, ok := authorizeStudio(c, models.PermStudioApplications)
+	if !ok {
+		return
+	}
+	inviteID, err := parseUintParam(c.Param("invite_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid invite ID")
+		return
+	}
+
+	db := config.GetDB()
+	var invite models.StudioInvite
+	if err := db.Where("studio_id = ?", studio.ID).First(&invite, inviteID).Error; err != nil {
+		utils.NotFound(c, "邀请码不存在")
+		return
+	}
+	if invite.RevokedAt != nil {
+		utils.BadRequest(c, "邀请码已撤销")
+		return
+	}
+	now := time.Now()
+	if err := db.Model(&invite).Update("revoked_at", &now).Error; err != nil {
+		utils.InternalServerError(c, "Failed to revoke invite")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "邀请码已撤销", invite)
+}
+
+// Redeem 服务者兑换邀请码，直接成为工作室成员（关联记录上记录所用邀请）
+func (ic *StudioInviteController) Redeem(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	var req RedeemInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	var relation models.ProviderStudioRelation
+	var studio models.Studio
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		var invite models.StudioInvite
+		if err := lockForUpdate(tx).Where("code = ?", code).First(&invite).Error; err != nil {
+			return errInviteInvalid
+		}
+		now := time.Now()
+		if invite.RevokedAt != nil || (invite.ExpiresAt != nil && now.After(*invite.ExpiresAt)) ||
+			(invite.MaxUses > 0 && invite.UsedCount >= invite.MaxUses) {
+			return errInviteInvalid
+		}
+		if invite.Username != "" && invite.Username != user.Username {
+			return errInviteInvalid
+		}
+		if err := tx.First(&studio, invite.StudioID).Error; err != nil || !studio.IsActive {
+			return errInviteInvalid
+		}
+
+		note := "通过邀请码 " + invite.Code + " 加入"
+		err := tx.Where("provider_id = ? AND studio_id = ?", userID, studio.ID).First(&relation).Error
+		switch {
+		case err == nil:
+			// 被工作室移除或拒绝（含自动拒绝）的服务者不能凭公开邀请码绕过工作室的决定，需定向邀请
+			if (relation.Status == models.StatusRemoved || relation.Status == models.StatusRejected) && invite.Username == "" {
+				return errInviteBlocked
+			}
+			if err := transitionRelationTx(tx, &relation,
+				[]models.RelationStatus{models.StatusPending, models.StatusRejected, models.StatusRemoved, models.StatusLeft},
+				models.StatusApproved, userID, note, map[string]interface{}{
+					"processed_at": &now,
+					"invite_id":    invite.ID,
+				}); err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			relation = models.ProviderStudioRelation{
+				ProviderID:      userID,
+				StudioID:        studio.ID,
+				Status:          models.StatusApproved,
+				AppliedAt:       now,
+				ProcessedAt:     &now,
+				StatusChangedAt: &now,
+				StatusChangedBy: userID,
+				StatusReason:    note,
+				InviteID:        &invite.ID,
+			}
+			if err := tx.Create(&relation).Error; err != nil {
+				return err
+			}
+			if err := logRelationStatusTx(tx, relation.ID, userID, "", models.StatusApproved, note); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		if err := tx.Model(&invite).UpdateColumn("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+			return err
+		}
+		return notify(tx, studio.OwnerID, models.NotificationStudioRelation,
+			"服务者已通过邀请加入", user.Nickname+" 通过邀请码加入了「"+studio.Name+"」", relation.ID)
+	})
+	if txErr != nil {
+		switch {
+		case errors.Is(txErr, errInviteInvalid), errors.Is(txErr, errInviteBlocked):
+			utils.BadRequest(c, txErr.Error())
+		case errors.Is(txErr, errRelationState):
+			utils.BadRequest(c, "你已是该工作室成员")
+		default:
+			utils.InternalServerError(c, "Failed to redeem invite")
+		}
+		return
+	}
+
+	relation.Studio = studio
+	utils.SuccessWithMessage(c, "已加入工作室", relation)
+}
@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/middleware"
+	"companion-platform-backend/models"
+	"companion-platform-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ModerationController struct{}
+
+// ReportReviewRequest 举报评价
+type ReportReviewRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
+
+// ModerateReviewRequest 审核操作备注（请求体可省略）
+type ModerateReviewRequest struct {
+	Notes string `json:"notes" binding:"max=1000"`
+}
+
+var errModerationState = errors.New("评价当前状态不允许该操作")
+
+// Report 任意登录用户举报一条评价（同一用户对同一评价只能举报一次）
+func (mc *ModerationController) Report(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	reviewID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid review ID")
+		return
+	}
+
+	var req ReportReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var review models.Review
+	if err := db.First(&review, reviewID).Error; err != nil {
+		utils.NotFound(c, "评价不存在")
+		return
+	}
+
+	var existing int64
+	db.Model(&models.ReviewReport{}).Where("review_id = ? AND reporter_id = ?", review.ID, userID).Count(&existing)
+	if existing > 0 {
+		utils.BadRequest(c, "你已举报过该评价")
+		return
+	}
+
+	report := models.ReviewReport{
+		ReviewID:   review.ID,
+		ReporterID: userID,
+		Reason:     req.Reason,
+		Status:     models.ReportStatusPending,
+	}
+	if err := db.Create(&report).Error; err != nil {
+		utils.InternalServerError(c, "举报失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "举报已提交", report)
+}
+
+// Queue 审核队列：有待处理举报的评价（含待处理举报明细），按最早举报时间排序
+func (mc *ModerationController) Queue(c *gin.Context) {
+	db := config.GetDB()
+	page, pageSize, offset := paginate(c)
+
+	pending := db.Model(&models.ReviewReport{}).Select("review_id").Where("status = ?", models.ReportStatusPending)
+	query := db.Model(&models.Review{}).Where("id IN (?)", pending)
+
+	var total int64
+	query.Count(&total)
+
+	var reviews []models.Review
+	if err := query.Preload("Player").
+		Preload("Reports", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("status = ?", models.ReportStatusPending).Preload("Reporter").Order("created_at ASC")
+		}).
+		Order("created_at ASC").Offset(offset).Limit(pageSize).Find(&reviews).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get moderation queue")
+		return
+	}
+
+	utils.PageSuccess(c, reviews, total, page, pageSize)
+}
+
+// Hide 隐藏评价：不再出现在公开列表与评分汇总中
+func (mc *ModerationController) Hide(c *gin.Context) {
+	mc.moderate(c, models.ModerationHide, "评价已隐藏", func(tx *gorm.DB, review *models.Review) error {
+		if review.DeletedAt.Valid || review.Status == models.ReviewStatusHidden {
+			return errModerationState
+		}
+		return tx.Model(review).Update("status", models.ReviewStatusHidden).Error
+	})
+}
+
+// Restore 恢复评价展示（隐藏或已删除的评价均可恢复）
+func (mc *ModerationController) Restore(c *gin.Context) {
+	mc.moderate(c, models.ModerationRestore, "评价已恢复", func(tx *gorm.DB, review *models.Review) error {
+		if !review.DeletedAt.Valid && review.Status == models.ReviewStatusVisible {
+			return errModerationState
+		}
+		return tx.Unscoped().Model(review).Updates(map[string]interface{}{
+			"status":     models.ReviewStatusVisible,
+			"deleted_at": nil,
+		}).Error
+	})
+}
+
+// Delete 删除评价（软删除，审核历史保留）
+func (mc *ModerationController) Delete(c *gin.Context) {
+	mc.moderate(c, models.ModerationDelete, "评价已删除", func(tx *gorm.DB, review *models.Review) error {
+		if review.DeletedAt.Valid {
+			return errModerationState
+		}
+		return tx.Delete(review).Error
+	})
+}
+
+// Dismiss 驳回举报，评价保持不变
+func (mc *ModerationController) Dismiss(c *gin.Context) {
+	mc.moderate(c, models.ModerationDismiss, "举报已驳回", func(tx *gorm.DB, review *models.Review) error {
+		if review.DeletedAt.Valid {
+			return errModerationState
+		}
+		return nil
+	})
+}
+
+// History 评价的审核历史、全部举报与修改历史
+func (mc *ModerationController) History(c *gin.Context) {
+	reviewID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid review ID")
+		return
+	}
+
+	db := config.GetDB()
+	var review models.Review
+	if err := db.Unscoped().Preload("Player").First(&review, reviewID).Error; err != nil {
+		utils.NotFound(c, "评价不存在")
+		return
+	}
+
+	var moderations []models.ReviewModeration
+	db.Where("review_id = ?", review.ID).Preload("Moderator").Order("created_at ASC, id ASC").Find(&moderations)
+	var reports []models.ReviewReport
+	db.Where("review_id = ?", review.ID).Preload("Reporter").Order("created_at ASC, id ASC").Find(&reports)
+	var revisions []models.ReviewRevision
+	db.Where("review_id = ?", review.ID).Order("created_at ASC, id ASC").Find(&revisions)
+
+	utils.Success(c, gin.H{
+		"review":      review,
+		"deleted":     review.DeletedAt.Valid,
+		"moderations": moderations,
+		"reports":     reports,
+		"revisions":   revisions,
+	})
+}
+
+// moderate 审核操作的公共流程：事务内执行操作 → 结案待处理举报 → 追加审核历史
+func (mc *ModerationController) moderate(c *gin.Context, action models.ModerationAction, message string,
+	apply func(tx *gorm.DB, review *models.Review) error) {
+
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	reviewID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid review ID")
+		return
+	}
+
+	var req ModerateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	now := time.Now()
+	var entry models.ReviewModeration
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		var review models.Review
+		if err := lockForUpdate(tx.Unscoped()).First(&review, reviewID).Error; err != nil {
+			return err
+		}
+		if err := apply(tx, &review); err != nil {
+			return err
+		}
+		if action != models.ModerationDismiss {
+			if err := refreshRatingScore(tx, review.TargetType, review.TargetID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.ReviewReport{}).
+			Where("review_id = ? AND status = ?", review.ID, models.ReportStatusPending).
+			Updates(map[string]interface{}{"status": models.ReportStatusHandled, "handled_at": &now}).Error; err != nil {
+			return err
+		}
+		entry = models.ReviewModeration{ReviewID: review.ID, ModeratorID: userID, Action: action, Notes: req.Notes}
+		return tx.Create(&entry).Error
+	})
+	if txErr != nil {
+		switch {
+		case errors.Is(txErr, gorm.ErrRecordNotFound):
+			utils.NotFound(c, "评价不存在")
+		case errors.Is(txErr, errModerationState):
+			utils.BadRequest(c, txErr.Error())
+		default:
+			utils.InternalServerError(c, "审核操作失败")
+		}
+		return
+	}
+
+	utils.SuccessWithMessage(c, message, entry)
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"time"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/middleware"
+	"companion-platform-backend/models"
+	"companion-platform-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type NotificationController struct{}
+
+// notify 在 tx 内给用户写一条站内通知
+func notify(tx *gorm.DB, userID uint, ntype models.NotificationType, title, content string, refID uint) error {
+	return tx.Create(&models.Notification{
+		UserID:  userID,
+		Type:    ntype,
+		Title:   title,
+		Content: content,
+		RefID:   refID,
+	}).Error
+}
+
+// List 当前用户的站内通知（unread=1 只看未读）
+func (nc *NotificationController) List(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	db := config.GetDB()
+	page, pageSize, offset := paginate(c)
+
+	query := db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if c.Query("unread") == "1" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var notifications []models.Notification
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&notifications).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get notifications")
+		return
+	}
+
+	utils.PageSuccess(c, notifications, total, page, pageSize)
+}
+
+// MarkRead 标记一条通知为已读
+func (nc *NotificationController) MarkRead(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	id, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid notification ID")
+		return
+	}
+
+	now := time.Now()
+	res := config.GetDB().Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", id, userID).
+		Update("read_at", &now)
+	if res.Error != nil {
+		utils.InternalServerError(c, "Failed to mark notification")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "已读", gin.H{"updated": res.RowsAffected})
+}
+
+// MarkAllRead 全部标记为已读
+func (nc *NotificationController) MarkAllRead(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	now := time.Now()
+	res := config.GetDB().Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", &now)
+	if res.Error != nil {
+		utils.InternalServerError(c, "Failed to mark notifications")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "已全部标记为已读", gin.H{"updated": res.RowsAffected})
+}
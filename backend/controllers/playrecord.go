@@ -1,7 +1,12 @@
 package controllers
 
 import (
+	"bytes"
+	"encoding/csv"
 	"errors"
+	"io"
+	"net/http"
+	"strconv"
 	"time"
 
 	"companion-platform-backend/config"
@@ -20,7 +25,9 @@ type PlayRecordController struct{}
 type CreatePlayRecordRequest struct {
 	PlayerID    uint               `json:"player_id" binding:"required"`
 	StudioID    uint               `json:"studio_id"`
-	GameName    string             `json:"game_name" binding:"required"`
+	GameID      uint               `json:"game_id"`      // 目录游戏ID，与 game_name 二选一
+	GameModeID  uint               `json:"game_mode_id"` // 目录模式ID，与 game_mode 二选一
+	GameName    string             `json:"game_name"`    // 游戏名称或别名，按目录解析为规范名称
 	GameMode    string             `json:"game_mode"`
 	SettleType  models.BalanceType `json:"settle_type" binding:"omitempty,oneof=money time point"`
 	Description string             `json:"description"`
@@ -33,6 +40,39 @@ type CompletePlayRecordRequest struct {
 	Settle   *bool           `json:"settle"` // 是否从玩家余额结算扣费，默认 true
 }
 
+// DisputePlayRecordRequest 玩家对已完成的一局发起申诉
+type DisputePlayRecordRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
+
+// ResolveDisputeRequest 服务者 / 工作室处理申诉
+type ResolveDisputeRequest struct {
+	Resolution   models.DisputeResolution `json:"resolution" binding:"required,oneof=refund partial reject"`
+	RefundAmount decimal.Decimal          `json:"refund_amount"` // 仅 partial 时使用，须在 (0, 已扣费额) 之间
+	Notes        string                   `json:"notes"`
+}
+
+// PostPlayEventRequest 服务者发布时间线事件
+type PostPlayEventRequest struct {
+	Type    models.PlayEventType `json:"type" binding:"required,oneof=match_start match_result score note"`
+	Content string               `json:"content" binding:"max=1000"`
+}
+
+// AddMatchOutcomeRequest 记录一场对局结果
+type AddMatchOutcomeRequest struct {
+	Result      models.MatchResult `json:"result" binding:"required,oneof=win loss draw"`
+	RankBefore  string             `json:"rank_before" binding:"max=50"`
+	RankAfter   string             `json:"rank_after" binding:"max=50"`
+	StarsGained int                `json:"stars_gained"`
+}
+
+// AmendPlayRecordRequest 修正已完成记录的时长 / 数额（至少提供一项）
+type AmendPlayRecordRequest struct {
+	Duration *uint            `json:"duration"`
+	Amount   *decimal.Decimal `json:"amount"`
+	Reason   string           `json:"reason" binding:"required,max=500"`
+}
+
 // Create 服务者发起一局陪玩（状态 active）
 func (pc *PlayRecordController) Create(c *gin.Context) {
 	userID, err := middleware.GetCurrentUserID(c)
@@ -52,12 +92,30 @@ func (pc *PlayRecordController) Create(c *gin.Context) {
 		settleType = models.BalanceTypeMoney
 	}
 
+	// 游戏与模式必须来自目录（支持别名），统一落规范名称
+	game, mode, err := resolveGame(config.GetDB(), req.GameID, req.GameModeID, req.GameName, req.GameMode)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	var modeID uint
+	var modeName string
+	if mode != nil {
+		modeID, modeName = mode.ID, mode.Name
+	}
+	if err := ensureStudioActiveTx(config.GetDB(), req.StudioID); err != nil {
+		utils.BadRequest(c, "工作室不存在或已停用")
+		return
+	}
+
 	record := models.PlayRecord{
 		PlayerID:    req.PlayerID,
 		ProviderID:  userID,
 		StudioID:    req.StudioID,
-		GameName:    req.GameName,
-		GameMode:    req.GameMode,
+		GameID:      game.ID,
+		GameModeID:  modeID,
+		GameName:    game.Name,
+		GameMode:    modeName,
 		StartTime:   time.Now(),
 		SettleType:  settleType,
 		Status:      models.PlayStatusActive,
@@ -110,28 +168,43 @@ func (pc *PlayRecordController) Complete(c *gin.Context) {
 	}
 
 	now := time.Now()
+	deadline := now.Add(config.GetConfig().Play.ConfirmWindow)
+	amount := req.Amount
 	txErr := db.Transaction(func(tx *gorm.DB) error {
-		// 结算扣费
-		if settle && req.Amount.GreaterThan(decimal.Zero) {
-			desc := record.GameName
-			if record.GameMode != "" {
-				desc += " · " + record.GameMode
+		// 结算扣费；订阅场次已预付，按单价计入并累计已用场次
+		charged := decimal.Zero
+		if record.SubscriptionID != nil {
+			price, err := useSubscriptionSessionTx(tx, *record.SubscriptionID)
+			if err != nil {
+				return err
 			}
+			amount, charged = price, price
+		} else if settle && req.Amount.GreaterThan(decimal.Zero) {
 			if _, err := adjustBalanceTx(tx, record.PlayerID, record.ProviderID, record.StudioID,
-				record.SettleType, req.Amount.Neg(), models.TransactionTypeConsume, userID, desc); err != nil {
+				record.SettleType, req.Amount.Neg(), models.TransactionTypeConsume, userID, recordDesc(&record)); err != nil {
 				return err
 			}
+			charged = req.Amount
 		}
+		// 完成后进入确认窗口：玩家可确认或申诉，到期自动确认
 		updates := map[string]interface{}{
-			"end_time": &now,
-			"duration": req.Duration,
-			"amount":   req.Amount,
-			"status":   models.PlayStatusCompleted,
+			"end_time":         &now,
+			"duration":         req.Duration,
+			"amount":           amount,
+			"charged_amount":   charged,
+			"settled":          record.SubscriptionID != nil || settle,
+			"status":           models.PlayStatusCompleted,
+			"confirm_status":   models.ConfirmStatusPending,
+			"confirm_deadline": &deadline,
 		}
 		return tx.Model(&record).Updates(updates).Error
 	})
 
 	if txErr != nil {
+		if errors.Is(txErr, errStudioInactive) {
+			utils.BadRequest(c, txErr.Error())
+			return
+		}
 		if errors.Is(txErr, errInsufficientBalance) {
 			utils.BadRequest(c, "玩家余额不足，无法结算")
 			return
@@ -144,8 +217,8 @@ func (pc *PlayRecordController) Complete(c *gin.Context) {
 	utils.SuccessWithMessage(c, "陪玩已完成", record)
 }
 
-// Cancel 服务者取消一局陪玩
-func (pc *PlayRecordController) Cancel(c *gin.Context) {
+// Start 服务者开始一场订阅预约（booked → active），开始时间记为当前时间
+func (pc *PlayRecordController) Start(c *gin.Context) {
 	userID, err := middleware.GetCurrentUserID(c)
 	if err != nil {
 		utils.Unauthorized(c, "User not found")
@@ -168,17 +241,99 @@ func (pc *PlayRecordController) Cancel(c *gin.Context) {
 		utils.Forbidden(c, "只有该局的服务者可以操作")
 		return
 	}
-	if record.Status != models.PlayStatusActive {
+	if record.Status != models.PlayStatusBooked {
+		utils.BadRequest(c, "只有已预约的场次可以开始")
+		return
+	}
+
+	res := db.Model(&models.PlayRecord{}).
+		Where("id = ? AND status = ?", record.ID, models.PlayStatusBooked).
+		Updates(map[string]interface{}{
+			"status":     models.PlayStatusActive,
+			"start_time": time.Now(),
+		})
+	if res.Error != nil {
+		utils.InternalServerError(c, "开始失败")
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.BadRequest(c, "只有已预约的场次可以开始")
+		return
+	}
+
+	db.First(&record, recordID)
+	utils.SuccessWithMessage(c, "陪玩已开始", record)
+}
+
+// Cancel 服务者取消一局陪玩（进行中或已预约）：玩家不承担费用；no_show 登记玩家爽约并按策略收取爽约费
+func (pc *PlayRecordController) Cancel(c *gin.Context) {
+	pc.cancel(c, false)
+}
+
+// CancelByPlayer 玩家取消自己的一局：按适用的取消策略判定免费或收取迟取消费
+func (pc *PlayRecordController) CancelByPlayer(c *gin.Context) {
+	pc.cancel(c, true)
+}
+
+// cancel 取消的公共流程：鉴权 → 按策略判定规则与费用 → 事务内取消、退订阅预付、收费
+func (pc *PlayRecordController) cancel(c *gin.Context, byPlayer bool) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	recordID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid record ID")
+		return
+	}
+
+	var req CancelPlayRecordRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var record models.PlayRecord
+	if err := db.First(&record, recordID).Error; err != nil {
+		utils.NotFound(c, "游玩记录不存在")
+		return
+	}
+	if byPlayer && record.PlayerID != userID {
+		utils.Forbidden(c, "只能取消自己参与的陪玩")
+		return
+	}
+	if !byPlayer && record.ProviderID != userID {
+		utils.Forbidden(c, "只有该局的服务者可以操作")
+		return
+	}
+	if record.Status != models.PlayStatusActive && record.Status != models.PlayStatusBooked {
 		utils.BadRequest(c, "该局已结束或已取消")
 		return
 	}
 
 	now := time.Now()
-	if err := db.Model(&record).Updates(map[string]interface{}{
-		"status":   models.PlayStatusCancelled,
-		"end_time": &now,
-	}).Error; err != nil {
-		utils.InternalServerError(c, "取消失败")
+	policy := effectivePolicy(db, record.ProviderID, record.StudioID)
+	rule, fee, err := evaluateCancel(policy, &record, byPlayer, req.NoShow, now)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		return cancelRecordTx(tx, &record, policy, rule, fee, userID, req.Reason, now)
+	})
+	if txErr != nil {
+		switch {
+		case errors.Is(txErr, errRecordState):
+			utils.BadRequest(c, txErr.Error())
+		case errors.Is(txErr, errStudioInactive):
+			utils.BadRequest(c, txErr.Error())
+		default:
+			utils.InternalServerError(c, "取消失败")
+		}
 		return
 	}
 
@@ -186,45 +341,913 @@ func (pc *PlayRecordController) Cancel(c *gin.Context) {
 	utils.SuccessWithMessage(c, "陪玩已取消", record)
 }
 
-// ListMine 玩家查看自己的游玩记录
-func (pc *PlayRecordController) ListMine(c *gin.Context) {
+// Confirm 玩家在确认窗口内确认一局已完成的陪玩
+func (pc *PlayRecordController) Confirm(c *gin.Context) {
 	userID, err := middleware.GetCurrentUserID(c)
 	if err != nil {
 		utils.Unauthorized(c, "User not found")
 		return
 	}
-	pc.list(c, "player_id = ?", userID)
+
+	recordID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid record ID")
+		return
+	}
+
+	db := config.GetDB()
+	record, ok := pc.loadPendingConfirm(c, recordID, userID)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if err := confirmPendingRecord(db, record.ID, now); err != nil {
+		if errors.Is(err, errDisputeState) {
+			utils.BadRequest(c, err.Error())
+		} else {
+			utils.InternalServerError(c, "确认失败")
+		}
+		return
+	}
+
+	db.First(record, recordID)
+	utils.SuccessWithMessage(c, "已确认", record)
 }
 
-// ListHosted 服务者查看自己主持的游玩记录
-func (pc *PlayRecordController) ListHosted(c *gin.Context) {
+// Dispute 玩家在确认窗口内对一局发起申诉，已扣费额转入冻结直至处理
+func (pc *PlayRecordController) Dispute(c *gin.Context) {
 	userID, err := middleware.GetCurrentUserID(c)
 	if err != nil {
 		utils.Unauthorized(c, "User not found")
 		return
 	}
-	pc.list(c, "provider_id = ?", userID)
+
+	recordID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid record ID")
+		return
+	}
+
+	var req DisputePlayRecordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	record, ok := pc.loadPendingConfirm(c, recordID, userID)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		// 条件更新：并发重复申诉只冻结一次
+		res := tx.Model(&models.PlayRecord{}).
+			Where("id = ? AND status = ? AND confirm_status = ?", record.ID, models.PlayStatusCompleted, models.ConfirmStatusPending).
+			Updates(map[string]interface{}{
+				"confirm_status": models.ConfirmStatusDisputed,
+				"dispute_reason": req.Reason,
+				"disputed_at":    &now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errDisputeState
+		}
+		if record.ChargedAmount.GreaterThan(decimal.Zero) {
+			return freezeBalanceTx(tx, record.PlayerID, record.ProviderID, record.StudioID, record.SettleType,
+				record.ChargedAmount, userID, "申诉冻结 · "+recordDesc(record))
+		}
+		return nil
+	})
+	if txErr != nil {
+		if errors.Is(txErr, errStudioInactive) || errors.Is(txErr, errDisputeState) {
+			utils.BadRequest(c, txErr.Error())
+		} else {
+			utils.InternalServerError(c, "申诉失败")
+		}
+		return
+	}
+
+	db.First(record, recordID)
+	utils.SuccessWithMessage(c, "申诉已提交", record)
 }
 
-func (pc *PlayRecordController) list(c *gin.Context, cond string, arg interface{}) {
+// loadPendingConfirm 取出玩家本人、处于确认窗口内的已完成记录；不满足时直接写出错误响应。
+// 窗口已过但尚未被定时任务处理的记录，在此顺带自动确认。
+func (pc *PlayRecordController) loadPendingConfirm(c *gin.Context, recordID, userID uint) (*models.PlayRecord, bool) {
 	db := config.GetDB()
-	page, pageSize, offset := paginate(c)
-	status := c.Query("status")
+	var record models.PlayRecord
+	if err := db.First(&record, recordID).Error; err != nil {
+		utils.NotFound(c, "游玩记录不存在")
+		return nil, false
+	}
+	if record.PlayerID != userID {
+		utils.Forbidden(c, "只能操作自己的游玩记录")
+		return nil, false
+	}
+	if record.Status != models.PlayStatusCompleted || record.ConfirmStatus != models.ConfirmStatusPending {
+		utils.BadRequest(c, "该局不在待确认状态")
+		return nil, false
+	}
+	if record.ConfirmDeadline != nil && time.Now().After(*record.ConfirmDeadline) {
+		if err := confirmPendingRecord(db, record.ID, *record.ConfirmDeadline); err != nil {
+			if errors.Is(err, errDisputeState) {
+				utils.BadRequest(c, err.Error())
+			} else {
+				utils.InternalServerError(c, "确认失败")
+			}
+			return nil, false
+		}
+		utils.BadRequest(c, "确认窗口已过，该局已自动确认")
+		return nil, false
+	}
+	return &record, true
+}
 
-	query := db.Model(&models.PlayRecord{}).Where(cond, arg)
-	if status != "" {
-		query = query.Where("status = ?", status)
+// confirmPendingRecord 条件更新为已确认：仅当记录仍为已完成且待确认，避免覆盖并发提交的申诉；未命中返回 errDisputeState
+func confirmPendingRecord(db *gorm.DB, recordID uint, at time.Time) error {
+	res := db.Model(&models.PlayRecord{}).
+		Where("id = ? AND status = ? AND confirm_status = ?", recordID, models.PlayStatusCompleted, models.ConfirmStatusPending).
+		Updates(map[string]interface{}{
+			"confirm_status": models.ConfirmStatusConfirmed,
+			"confirmed_at":   &at,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errDisputeState
 	}
+	return nil
+}
 
-	var total int64
-	query.Count(&total)
+// ResolveDispute 服务者或记录所属工作室的所有者处理申诉：全额退款 / 部分退款 / 驳回。
+// 处理时先解冻全部已扣费额，再把应退部分以退款流水加回玩家余额。
+func (pc *PlayRecordController) ResolveDispute(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
 
-	var records []models.PlayRecord
-	if err := query.Preload("Player").Preload("Provider").Preload("Studio").
-		Order("start_time DESC").Offset(offset).Limit(pageSize).Find(&records).Error; err != nil {
-		utils.InternalServerError(c, "Failed to get play records")
+	recordID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid record ID")
+		return
+	}
+
+	var req ResolveDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var record models.PlayRecord
+	if err := db.First(&record, recordID).Error; err != nil {
+		utils.NotFound(c, "游玩记录不存在")
+		return
+	}
+	if !canManageRecord(c, &record, userID, models.PermStudioBalance) {
+		utils.Forbidden(c, "只有该局的服务者或所属工作室可以处理申诉")
+		return
+	}
+	if record.ConfirmStatus != models.ConfirmStatusDisputed {
+		utils.BadRequest(c, "该局没有待处理的申诉")
+		return
+	}
+
+	refund := decimal.Zero
+	switch req.Resolution {
+	case models.DisputeResolutionRefund:
+		refund = record.ChargedAmount
+	case models.DisputeResolutionPartial:
+		if req.RefundAmount.LessThanOrEqual(decimal.Zero) || req.RefundAmount.GreaterThanOrEqual(record.ChargedAmount) {
+			utils.BadRequest(c, "部分退款金额须大于 0 且小于已扣费额")
+			return
+		}
+		refund = req.RefundAmount
+	}
+
+	now := time.Now()
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		// 先条件更新申诉状态再动余额：并发处理同一申诉时只有一个请求能解冻 / 退款
+		res := tx.Model(&models.PlayRecord{}).
+			Where("id = ? AND confirm_status = ?", record.ID, models.ConfirmStatusDisputed).
+			Updates(map[string]interface{}{
+				"confirm_status":   models.ConfirmStatusResolved,
+				"resolution":       req.Resolution,
+				"refund_amount":    refund,
+				"resolution_notes": req.Notes,
+				"resolver_id":      userID,
+				"resolved_at":      &now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errDisputeState
+		}
+
+		if record.ChargedAmount.GreaterThan(decimal.Zero) {
+			if err := freezeBalanceTx(tx, record.PlayerID, record.ProviderID, record.StudioID, record.SettleType,
+				record.ChargedAmount.Neg(), userID, "申诉解冻 · "+recordDesc(&record)); err != nil {
+				return err
+			}
+		}
+		if refund.GreaterThan(decimal.Zero) {
+			if _, err := adjustBalanceTx(tx, record.PlayerID, record.ProviderID, record.StudioID, record.SettleType,
+				refund, models.TransactionTypeRefund, userID, "申诉退款 · "+recordDesc(&record)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if txErr != nil {
+		if errors.Is(txErr, errDisputeState) || errors.Is(txErr, errStudioInactive) {
+			utils.BadRequest(c, txErr.Error())
+		} else {
+			utils.InternalServerError(c, "处理申诉失败")
+		}
+		return
+	}
+
+	db.First(&record, recordID)
+	utils.SuccessWithMessage(c, "申诉已处理", record)
+}
+
+// Amend 在修正时限内修改已完成记录的时长 / 数额。
+// 若该局完成时已从余额扣费，差额自动以补扣（consume）或退回（refund）流水入账；每次修正留一条修订历史。
+func (pc *PlayRecordController) Amend(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	recordID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid record ID")
+		return
+	}
+
+	var req AmendPlayRecordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if req.Duration == nil && req.Amount == nil {
+		utils.BadRequest(c, "请提供要修正的时长或数额")
+		return
+	}
+	if req.Amount != nil && req.Amount.IsNegative() {
+		utils.BadRequest(c, "数额不能为负")
+		return
+	}
+
+	db := config.GetDB()
+	var record models.PlayRecord
+	if err := db.First(&record, recordID).Error; err != nil {
+		utils.NotFound(c, "游玩记录不存在")
+		return
+	}
+	if !canManageRecord(c, &record, userID, models.PermStudioBalance) {
+		utils.Forbidden(c, "只有该局的服务者或所属工作室可以修正")
+		return
+	}
+	if record.Status != models.PlayStatusCompleted {
+		utils.BadRequest(c, "只有已完成的记录可以修正")
+		return
+	}
+	if record.ConfirmStatus == models.ConfirmStatusDisputed || record.ConfirmStatus == models.ConfirmStatusResolved {
+		utils.BadRequest(c, "该局已进入申诉流程，请通过申诉处理调整")
+		return
+	}
+	if record.EndTime == nil || time.Since(*record.EndTime) > config.GetConfig().Play.AmendWindow {
+		utils.BadRequest(c, "已超过可修正时限")
+		return
+	}
+
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		// 加锁重读：差额须基于最新的已扣费额计算，避免并发修正重复补扣 / 退回
+		if err := lockForUpdate(tx).First(&record, recordID).Error; err != nil {
+			return err
+		}
+		if record.Status != models.PlayStatusCompleted {
+			return errRecordState
+		}
+		if record.ConfirmStatus == models.ConfirmStatusDisputed || record.ConfirmStatus == models.ConfirmStatusResolved {
+			return errDisputeState
+		}
+
+		revision := models.PlayRecordRevision{
+			PlayRecordID:      record.ID,
+			EditorID:          userID,
+			OldDuration:       record.Duration,
+			NewDuration:       record.Duration,
+			OldAmount:         record.Amount,
+			NewAmount:         record.Amount,
+			BalanceAdjustment: decimal.Zero,
+			Reason:            req.Reason,
+		}
+		if req.Duration != nil {
+			revision.NewDuration = *req.Duration
+		}
+		if req.Amount != nil {
+			revision.NewAmount = *req.Amount
+		}
+
+		// 仅对完成时按余额结算的记录联动余额（已扣费额为 0 也算）：差额 = 新数额 - 已扣费额
+		charged := record.ChargedAmount
+		diff := decimal.Zero
+		if record.Settled || record.ChargedAmount.GreaterThan(decimal.Zero) {
+			diff = revision.NewAmount.Sub(record.ChargedAmount)
+			charged = revision.NewAmount
+			revision.BalanceAdjustment = diff.Neg()
+		}
+
+		if !diff.IsZero() {
+			txType := models.TransactionTypeConsume
+			if diff.IsNegative() {
+				txType = models.TransactionTypeRefund
+			}
+			if _, err := adjustBalanceTx(tx, record.PlayerID, record.ProviderID, record.StudioID, record.SettleType,
+				diff.Neg(), txType, userID, "修正 · "+recordDesc(&record)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		return tx.Model(&record).Updates(map[string]interface{}{
+			"duration":       revision.NewDuration,
+			"amount":         revision.NewAmount,
+			"charged_amount": charged,
+		}).Error
+	})
+	if txErr != nil {
+		if errors.Is(txErr, errRecordState) || errors.Is(txErr, errDisputeState) || errors.Is(txErr, errStudioInactive) {
+			utils.BadRequest(c, txErr.Error())
+			return
+		}
+		if errors.Is(txErr, errInsufficientBalance) {
+			utils.BadRequest(c, "玩家余额不足，无法补扣差额")
+			return
+		}
+		utils.InternalServerError(c, "修正失败")
 		return
 	}
 
-	utils.PageSuccess(c, records, total, page, pageSize)
+	db.Preload("Revisions", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at ASC") }).First(&record, recordID)
+	utils.SuccessWithMessage(c, "记录已修正", record)
+}
+
+// Revisions 查看记录的修正历史（本局玩家、服务者或所属工作室）
+func (pc *PlayRecordController) Revisions(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	recordID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid record ID")
+		return
+	}
+
+	db := config.GetDB()
+	var record models.PlayRecord
+	if err := db.First(&record, recordID).Error; err != nil {
+		utils.NotFound(c, "游玩记录不存在")
+		return
+	}
+	if record.PlayerID != userID && !canManageRecord(c, &record, userID, models.PermStudioView) {
+		utils.Forbidden(c, "无权查看该记录")
+		return
+	}
+
+	var revisions []models.PlayRecordRevision
+	if err := db.Where("play_record_id = ?", recordID).Preload("Editor").
+		Order("created_at ASC").Find(&revisions).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get revisions")
+		return
+	}
+
+	utils.Success(c, revisions)
+}
+
+// Detail 查看单条记录详情（含时间线、战绩与修正历史），本局玩家、服务者或所属工作室可见
+func (pc *PlayRecordController) Detail(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	recordID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid record ID")
+		return
+	}
+
+	db := config.GetDB()
+	var record models.PlayRecord
+	if err := db.Preload("Player").Preload("Provider").Preload("Studio").
+		Preload("Events", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at ASC, id ASC") }).
+		Preload("Revisions", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at ASC") }).
+		Preload("Outcomes", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at ASC, id ASC") }).
+		First(&record, recordID).Error; err != nil {
+		utils.NotFound(c, "游玩记录不存在")
+		return
+	}
+	if record.PlayerID != userID && !canManageRecord(c, &record, userID, models.PermStudioView) {
+		utils.Forbidden(c, "无权查看该记录")
+		return
+	}
+
+	utils.Success(c, record)
+}
+
+// PostEvent 服务者向进行中的一局发布时间线事件（开局、结果、比分、备注），同时刷新活动时间
+func (pc *PlayRecordController) PostEvent(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	recordID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid record ID")
+		return
+	}
+
+	var req PostPlayEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	record, ok := pc.loadHostedActive(c, recordID, userID)
+	if !ok {
+		return
+	}
+
+	event := models.PlayRecordEvent{
+		PlayRecordID: record.ID,
+		AuthorID:     userID,
+		Type:         req.Type,
+		Content:      req.Content,
+	}
+	now := time.Now()
+	txErr := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		return touchActivityTx(tx, record.ID, now)
+	})
+	if txErr != nil {
+		utils.InternalServerError(c, "发布失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "已发布", event)
+}
+
+// Heartbeat 服务者心跳：不产生事件，仅刷新活动时间；首次心跳即为该局启用无活动监测
+func (pc *PlayRecordController) Heartbeat(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	recordID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid record ID")
+		return
+	}
+
+	record, ok := pc.loadHostedActive(c, recordID, userID)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if err := touchActivityTx(config.GetDB(), record.ID, now); err != nil {
+		utils.InternalServerError(c, "心跳失败")
+		return
+	}
+
+	utils.Success(c, gin.H{"last_activity_at": now})
+}
+
+// AddOutcome 服务者为本局追加一场对局结果（进行中或已完成的记录均可）
+func (pc *PlayRecordController) AddOutcome(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	recordID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid record ID")
+		return
+	}
+
+	var req AddMatchOutcomeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var record models.PlayRecord
+	if err := db.First(&record, recordID).Error; err != nil {
+		utils.NotFound(c, "游玩记录不存在")
+		return
+	}
+	if record.ProviderID != userID {
+		utils.Forbidden(c, "只有该局的服务者可以操作")
+		return
+	}
+	if record.Status == models.PlayStatusCancelled {
+		utils.BadRequest(c, "已取消的记录不能登记战绩")
+		return
+	}
+
+	outcome := models.MatchOutcome{
+		PlayRecordID: record.ID,
+		ProviderID:   record.ProviderID,
+		GameID:       record.GameID,
+		Result:       req.Result,
+		RankBefore:   req.RankBefore,
+		RankAfter:    req.RankAfter,
+		StarsGained:  req.StarsGained,
+	}
+	if err := db.Create(&outcome).Error; err != nil {
+		utils.InternalServerError(c, "登记战绩失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "战绩已登记", outcome)
+}
+
+// DeleteOutcome 服务者删除误登记的对局结果
+func (pc *PlayRecordController) DeleteOutcome(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	outcomeID, err := parseUintParam(c.Param("outcome_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid outcome ID")
+		return
+	}
+
+	res := config.GetDB().Where("id = ? AND provider_id = ?", outcomeID, userID).Delete(&models.MatchOutcome{})
+	if res.Error != nil {
+		utils.InternalServerError(c, "删除失败")
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.NotFound(c, "战绩不存在")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "战绩已删除", nil)
+}
+
+// loadHostedActive 取出当前服务者主持的、进行中的记录；不满足时直接写出错误响应
+func (pc *PlayRecordController) loadHostedActive(c *gin.Context, recordID, userID uint) (*models.PlayRecord, bool) {
+	var record models.PlayRecord
+	if err := config.GetDB().First(&record, recordID).Error; err != nil {
+		utils.NotFound(c, "游玩记录不存在")
+		return nil, false
+	}
+	if record.ProviderID != userID {
+		utils.Forbidden(c, "只有该局的服务者可以操作")
+		return nil, false
+	}
+	if record.Status != models.PlayStatusActive {
+		utils.BadRequest(c, "该局已结束或已取消")
+		return nil, false
+	}
+	return &record, true
+}
+
+// touchActivityTx 刷新记录的活动时间，并清除无活动标记
+func touchActivityTx(tx *gorm.DB, recordID uint, now time.Time) error {
+	return tx.Model(&models.PlayRecord{}).Where("id = ?", recordID).Updates(map[string]interface{}{
+		"last_activity_at": &now,
+		"idle_at":          nil,
+	}).Error
+}
+
+// canManageRecord 当前用户是否可以以「服务方」身份处理该记录：本局服务者，或记录所属工作室中拥有 perm 权限的所有者 / 员工
+func canManageRecord(c *gin.Context, record *models.PlayRecord, userID uint, perm models.StudioPermission) bool {
+	role, _ := middleware.GetCurrentUserRole(c)
+	switch role {
+	case models.RoleProvider:
+		return record.ProviderID == userID
+	case models.RoleStudio:
+		if record.StudioID == 0 {
+			return false
+		}
+		db := config.GetDB()
+		var studio models.Studio
+		return db.First(&studio, record.StudioID).Error == nil && hasStudioPermission(db, &studio, userID, perm)
+	}
+	return false
+}
+
+// recordDesc 流水描述：游戏名 · 模式
+func recordDesc(record *models.PlayRecord) string {
+	desc := record.GameName
+	if record.GameMode != "" {
+		desc += " · " + record.GameMode
+	}
+	return desc
+}
+
+// ListMine 玩家查看自己的游玩记录
+func (pc *PlayRecordController) ListMine(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+	pc.list(c, "player_id = ?", userID)
+}
+
+// ListHosted 服务者查看自己主持的游玩记录
+func (pc *PlayRecordController) ListHosted(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+	pc.list(c, "provider_id = ?", userID)
+}
+
+// ExportMine 玩家按筛选条件导出自己的游玩记录（CSV）
+func (pc *PlayRecordController) ExportMine(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+	pc.export(c, "player_id = ?", userID)
+}
+
+// ExportHosted 服务者按筛选条件导出主持的游玩记录（CSV）
+func (pc *PlayRecordController) ExportHosted(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+	pc.export(c, "provider_id = ?", userID)
+}
+
+// playRecordSortColumns 列表允许的排序字段
+var playRecordSortColumns = map[string]string{
+	"start_time": "start_time",
+	"end_time":   "end_time",
+	"amount":     "amount",
+	"duration":   "duration",
+}
+
+// exportMaxRows 单次 CSV 导出的最大行数
+const exportMaxRows = 10000
+
+// PlayRecordSummaryRow 筛选结果按结算类型的汇总（不同结算类型的数额单位不同，不能直接相加）
+type PlayRecordSummaryRow struct {
+	SettleType    models.BalanceType `json:"settle_type"`
+	Count         int64              `json:"count"`
+	TotalDuration int64              `json:"total_duration"`
+	TotalAmount   decimal.Decimal    `json:"total_amount"`
+}
+
+// PlayRecordSummary 筛选结果汇总
+type PlayRecordSummary struct {
+	TotalDuration int64                  `json:"total_duration"` // 分钟
+	BySettleType  []PlayRecordSummaryRow `json:"by_settle_type"`
+}
+
+// filterQuery 在基础条件上应用列表筛选参数：
+// status / start_from / start_to（日期或 RFC3339，start_to 为日期时含当天）/ game_id / game（名称或别名）/
+// game_mode_id / game_mode / player_id / provider_id / studio_id / settle_type / amount_min / amount_max
+func (pc *PlayRecordController) filterQuery(c *gin.Context, cond string, arg interface{}) (*gorm.DB, error) {
+	db := config.GetDB()
+	query := db.Model(&models.PlayRecord{}).Where(cond, arg)
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if v := c.Query("start_from"); v != "" {
+		t, _, err := parseTimeQuery(v)
+		if err != nil {
+			return nil, errors.New("start_from 格式错误")
+		}
+		query = query.Where("start_time >= ?", t)
+	}
+	if v := c.Query("start_to"); v != "" {
+		t, dateOnly, err := parseTimeQuery(v)
+		if err != nil {
+			return nil, errors.New("start_to 格式错误")
+		}
+		if dateOnly {
+			query = query.Where("start_time < ?", t.AddDate(0, 0, 1))
+		} else {
+			query = query.Where("start_time <= ?", t)
+		}
+	}
+
+	gameID, _ := parseUintParam(c.DefaultQuery("game_id", "0"))
+	modeID, _ := parseUintParam(c.DefaultQuery("game_mode_id", "0"))
+	if gameID != 0 || c.Query("game") != "" {
+		// 历史记录可能属于已停用的游戏，或是未归入目录的自由文本（game_id = 0），均按名称一并匹配
+		game, mode, err := resolveHistoryGame(db, gameID, modeID, c.Query("game"), c.Query("game_mode"))
+		modeName := normalizeGameText(c.Query("game_mode"))
+		switch {
+		case game == nil && gameID != 0:
+			return nil, err
+		case game == nil:
+			legacy := db.Where("game_id = 0 AND LOWER(game_name) = ?", normalizeGameText(c.Query("game")))
+			if modeName != "" {
+				legacy = legacy.Where("LOWER(game_mode) = ?", modeName)
+			}
+			query = query.Where(legacy)
+		case err != nil && modeID != 0:
+			return nil, err
+		default:
+			names := []string{normalizeGameText(game.Name)}
+			if name := normalizeGameText(c.Query("game")); name != "" {
+				names = append(names, name)
+			}
+			catalog := db.Where("game_id = ?", game.ID)
+			legacy := db.Where("game_id = 0 AND LOWER(game_name) IN ?", names)
+			if mode != nil {
+				catalog = catalog.Where("game_mode_id = ?", mode.ID)
+				modeNames := []string{normalizeGameText(mode.Name)}
+				if modeName != "" {
+					modeNames = append(modeNames, modeName)
+				}
+				legacy = legacy.Where("LOWER(game_mode) IN ?", modeNames)
+			} else if modeName != "" {
+				// 模式不在目录中：只可能匹配按原文保存的模式名称
+				catalog = catalog.Where("LOWER(game_mode) = ?", modeName)
+				legacy = legacy.Where("LOWER(game_mode) = ?", modeName)
+			}
+			query = query.Where(catalog.Or(legacy))
+		}
+	} else if mode := c.Query("game_mode"); mode != "" {
+		query = query.Where("game_mode = ?", mode)
+	}
+
+	for _, key := range []string{"player_id", "provider_id", "studio_id"} {
+		if v := c.Query(key); v != "" {
+			id, err := parseUintParam(v)
+			if err != nil {
+				return nil, errors.New(key + " 格式错误")
+			}
+			query = query.Where(key+" = ?", id)
+		}
+	}
+	if v := c.Query("settle_type"); v != "" {
+		query = query.Where("settle_type = ?", v)
+	}
+	if v := c.Query("amount_min"); v != "" {
+		d, err := decimal.NewFromString(v)
+		if err != nil {
+			return nil, errors.New("amount_min 格式错误")
+		}
+		query = query.Where("amount >= ?", d)
+	}
+	if v := c.Query("amount_max"); v != "" {
+		d, err := decimal.NewFromString(v)
+		if err != nil {
+			return nil, errors.New("amount_max 格式错误")
+		}
+		query = query.Where("amount <= ?", d)
+	}
+	return query, nil
+}
+
+// orderClause 解析 sort / order 参数，默认按开始时间倒序
+func (pc *PlayRecordController) orderClause(c *gin.Context) string {
+	col, ok := playRecordSortColumns[c.Query("sort")]
+	if !ok {
+		col = "start_time"
+	}
+	dir := "DESC"
+	if c.Query("order") == "asc" {
+		dir = "ASC"
+	}
+	return col + " " + dir + ", id " + dir
+}
+
+func (pc *PlayRecordController) list(c *gin.Context, cond string, arg interface{}) {
+	page, pageSize, offset := paginate(c)
+
+	query, err := pc.filterQuery(c, cond, arg)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	var total int64
+	query.Session(&gorm.Session{}).Count(&total)
+
+	summary := PlayRecordSummary{BySettleType: []PlayRecordSummaryRow{}}
+	if err := query.Session(&gorm.Session{}).
+		Select("settle_type, COUNT(*) as count, COALESCE(SUM(duration),0) as total_duration, COALESCE(SUM(amount),0) as total_amount").
+		Group("settle_type").Scan(&summary.BySettleType).Error; err != nil {
+		utils.InternalServerError(c, "Failed to summarize play records")
+		return
+	}
+	for _, row := range summary.BySettleType {
+		summary.TotalDuration += row.TotalDuration
+	}
+
+	var records []models.PlayRecord
+	if err := query.Preload("Player").Preload("Provider").Preload("Studio").
+		Order(pc.orderClause(c)).Offset(offset).Limit(pageSize).Find(&records).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get play records")
+		return
+	}
+
+	utils.PageSuccessWithSummary(c, records, total, page, pageSize, summary)
+}
+
+// export 按与列表相同的筛选与排序导出 CSV（带 UTF-8 BOM，便于 Excel 打开）
+func (pc *PlayRecordController) export(c *gin.Context, cond string, arg interface{}) {
+	query, err := pc.filterQuery(c, cond, arg)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	var records []models.PlayRecord
+	if err := query.Preload("Player").Preload("Provider").
+		Order(pc.orderClause(c)).Limit(exportMaxRows).Find(&records).Error; err != nil {
+		utils.InternalServerError(c, "Failed to export play records")
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\xEF\xBB\xBF")
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"ID", "开始时间", "结束时间", "玩家", "服务者", "工作室ID", "游戏", "模式", "时长(分钟)", "结算类型", "数额", "状态"})
+	for _, r := range records {
+		endTime := ""
+		if r.EndTime != nil {
+			endTime = r.EndTime.Format("2006-01-02 15:04:05")
+		}
+		w.Write([]string{
+			strconv.FormatUint(uint64(r.ID), 10),
+			r.StartTime.Format("2006-01-02 15:04:05"),
+			endTime,
+			displayName(&r.Player),
+			displayName(&r.Provider),
+			strconv.FormatUint(uint64(r.StudioID), 10),
+			r.GameName,
+			r.GameMode,
+			strconv.FormatUint(uint64(r.Duration), 10),
+			string(r.SettleType),
+			r.Amount.StringFixed(2),
+			string(r.Status),
+		})
+	}
+	w.Flush()
+
+	filename := "play-records-" + time.Now().Format("20060102150405") + ".csv"
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// parseTimeQuery 解析查询参数中的时间：支持 2006-01-02（dateOnly=true，本地时区零点）与 RFC3339
+func parseTimeQuery(s string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, s)
+	return t, false, err
+}
+
+// displayName 用户展示名：优先昵称，其次用户名
+func displayName(u *models.User) string {
+	if u.Nickname != "" {
+		return u.Nickname
+	}
+	return u.Username
 }
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"companion-platform-backend/config"
+	"companion-platform-backend/models"
+	"companion-platform-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ProviderController struct{}
+
+// MatchStatRow 单个游戏的对局统计
+type MatchStatRow struct {
+	GameID         uint    `json:"game_id"`
+	GameName       string  `json:"game_name"`
+	Matches        int64   `json:"matches"`
+	Wins           int64   `json:"wins"`
+	WinRate        float64 `json:"win_rate"` // 0-1
+	AvgStarsGained float64 `json:"avg_stars_gained"`
+	RankedMatches  int64   `json:"ranked_matches"`  // 同时登记了赛前、赛后段位的场次
+	AvgRankGained  float64 `json:"avg_rank_gained"` // 每场平均段位变化（升段 +1、掉段 -1），按 ranked_matches 平均
+	RankGained     int64   `json:"-"`
+}
+
+// MatchStats 服务者的对局统计（总体 + 按游戏）
+type MatchStats struct {
+	Matches        int64          `json:"matches"`
+	Wins           int64          `json:"wins"`
+	WinRate        float64        `json:"win_rate"`
+	AvgStarsGained float64        `json:"avg_stars_gained"`
+	RankedMatches  int64          `json:"ranked_matches"`
+	AvgRankGained  float64        `json:"avg_rank_gained"`
+	ByGame         []MatchStatRow `json:"by_game"`
+}
+
+// providerMatchStats 汇总服务者登记的对局结果；gameID 非 0 时只统计该游戏。
+// 已取消记录上的对局不计入，避免先登记胜场再取消刷数据。
+// 段位是各游戏自定义的文本（如「钻石III」），无法直接相减：赛前、赛后段位不同即视为一次段位变化，
+// 方向取本场星数变化的正负，星数未登记时按胜负判断。
+func providerMatchStats(db *gorm.DB, providerID, gameID uint) MatchStats {
+	ranked := "match_outcomes.rank_before <> '' AND match_outcomes.rank_after <> ''"
+	query := db.Model(&models.MatchOutcome{}).
+		Select("match_outcomes.game_id, games.name as game_name, COUNT(*) as matches, "+
+			"SUM(CASE WHEN match_outcomes.result = ? THEN 1 ELSE 0 END) as wins, "+
+			"AVG(match_outcomes.stars_gained) as avg_stars_gained, "+
+			"SUM(CASE WHEN "+ranked+" THEN 1 ELSE 0 END) as ranked_matches, "+
+			"SUM(CASE WHEN "+ranked+" AND match_outcomes.rank_before <> match_outcomes.rank_after THEN "+
+			"CASE WHEN match_outcomes.stars_gained > 0 THEN 1 WHEN match_outcomes.stars_gained < 0 THEN -1 "+
+			"WHEN match_outcomes.result = ? THEN 1 WHEN match_outcomes.result = ? THEN -1 ELSE 0 END "+
+			"ELSE 0 END) as rank_gained",
+			models.MatchResultWin, models.MatchResultWin, models.MatchResultLoss).
+		Joins("JOIN play_records ON play_records.id = match_outcomes.play_record_id AND play_records.status <> ?", models.PlayStatusCancelled).
+		Joins("LEFT JOIN games ON games.id = match_outcomes.game_id").
+		Where("match_outcomes.provider_id = ?", providerID)
+	if gameID != 0 {
+		query = query.Where("match_outcomes.game_id = ?", gameID)
+	}
+
+	stats := MatchStats{ByGame: []MatchStatRow{}}
+	query.Group("match_outcomes.game_id, games.name").Order("matches DESC").Scan(&stats.ByGame)
+
+	var stars float64
+	var rankGained int64
+	for i := range stats.ByGame {
+		row := &stats.ByGame[i]
+		if row.Matches > 0 {
+			row.WinRate = float64(row.Wins) / float64(row.Matches)
+		}
+		if row.RankedMatches > 0 {
+			row.AvgRankGained = float64(row.RankGained) / float64(row.RankedMatches)
+		}
+		stats.Matches += row.Matches
+		stats.Wins += row.Wins
+		stats.RankedMatches += row.RankedMatches
+		stars += row.AvgStarsGained * float64(row.Matches)
+		rankGained += row.RankGained
+	}
+	if stats.Matches > 0 {
+		stats.WinRate = float64(stats.Wins) / float64(stats.Matches)
+		stats.AvgStarsGained = stars / float64(stats.Matches)
+	}
+	if stats.RankedMatches > 0 {
+		stats.AvgRankGained = float64(rankGained) / float64(stats.RankedMatches)
+	}
+	return stats
+}
+
+// List 服务者列表（公开）：keyword 按昵称/用户名搜索，sort=ranking 按排名分降序，默认按注册时间倒序
+func (pc *ProviderController) List(c *gin.Context) {
+	db := config.GetDB()
+	page, pageSize, offset := paginate(c)
+
+	query := db.Model(&models.User{}).Where("users.role = ? AND users.is_active = ?", models.RoleProvider, true)
+	if keyword := c.Query("keyword"); keyword != "" {
+		query = query.Where("users.nickname LIKE ? OR users.username LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	if c.Query("sort") == "ranking" {
+		query = rankingOrder(query, "users", models.ReviewTargetProvider)
+	} else {
+		query = query.Order("users.created_at DESC")
+	}
+
+	var providers []models.User
+	if err := query.Preload("RatingScore").Offset(offset).Limit(pageSize).Find(&providers).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get provider list")
+		return
+	}
+
+	utils.PageSuccess(c, providers, total, page, pageSize)
+}
+
+// Profile 服务者公开主页：基本信息 + 对局统计 + 评价高频标签
+func (pc *ProviderController) Profile(c *gin.Context) {
+	providerID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid provider ID")
+		return
+	}
+
+	db := config.GetDB()
+	var provider models.User
+	if err := db.Where("id = ? AND role = ?", providerID, models.RoleProvider).First(&provider).Error; err != nil {
+		utils.NotFound(c, "服务者不存在")
+		return
+	}
+
+	topTags := reviewTagCounts(db, models.ReviewTargetProvider, provider.ID, false)
+	if len(topTags) > 5 {
+		topTags = topTags[:5]
+	}
+
+	utils.Success(c, gin.H{
+		"provider":    provider,
+		"match_stats": providerMatchStats(db, provider.ID, 0),
+		"top_tags":    topTags,
+	})
+}
+
+// MatchStatsByProvider 服务者的对局统计（公开，可按 game_id 过滤）
+func (pc *ProviderController) MatchStatsByProvider(c *gin.Context) {
+	providerID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid provider ID")
+		return
+	}
+	gameID, _ := parseUintParam(c.DefaultQuery("game_id", "0"))
+
+	utils.Success(c, providerMatchStats(config.GetDB(), providerID, gameID))
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rankingBatchSize 定时任务每轮最多重算的排名分条数
+const rankingBatchSize = 500
+
+// platformMeanDrift 平台均分变动超过该值时，按旧均分计算的排名分视为过期，不等 Rating.RefreshAfter 即重算
+const platformMeanDrift = 0.05
+
+// platformMean 某类对象全部公开评价的平均分；没有评价时返回 0
+func platformMean(db *gorm.DB, targetType models.ReviewTargetType) float64 {
+	var mean *float64
+	db.Model(&models.Review{}).
+		Where("target_type = ? AND status = ?", targetType, models.ReviewStatusVisible).
+		Select("AVG(rating)").Scan(&mean)
+	if mean == nil {
+		return 0
+	}
+	return *mean
+}
+
+// computeRatingScore 按公开评价计算排名分：
+// 每条评价权重 w = 0.5^(评价时长 / 半衰期)，排名分 = (C·m + Σw·r) / (C + Σw)，
+// 其中 m 为平台均分、C 为先验权重。评价越少、越旧，越接近平台均分。
+func computeRatingScore(db *gorm.DB, targetType models.ReviewTargetType, targetID uint, now time.Time) models.RatingScore {
+	cfg := config.GetConfig().Rating
+	mean := platformMean(db, targetType)
+
+	var rows []struct {
+		Rating    int
+		CreatedAt time.Time
+	}
+	db.Model(&models.Review{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, models.ReviewStatusVisible).
+		Select("rating, created_at").Scan(&rows)
+
+	var weighted, weights float64
+	for _, r := range rows {
+		w := 1.0
+		if cfg.HalfLife > 0 {
+			age := now.Sub(r.CreatedAt)
+			if age < 0 {
+				age = 0
+			}
+			w = math.Pow(0.5, float64(age)/float64(cfg.HalfLife))
+		}
+		weighted += w * float64(r.Rating)
+		weights += w
+	}
+
+	score := models.RatingScore{
+		TargetType:      targetType,
+		TargetID:        targetID,
+		EffectiveWeight: weights,
+		ReviewCount:     int64(len(rows)),
+		PlatformMean:    mean,
+		RefreshedAt:     now,
+	}
+	if weights > 0 {
+		score.DecayedAverage = weighted / weights
+	}
+	if denom := cfg.PriorWeight + weights; denom > 0 {
+		score.Score = (cfg.PriorWeight*mean + weighted) / denom
+	}
+	return score
+}
+
+// refreshRatingScore 重算并保存单个对象的排名分（评价新增、隐藏、恢复、删除或修改后调用）
+func refreshRatingScore(db *gorm.DB, targetType models.ReviewTargetType, targetID uint) error {
+	score := computeRatingScore(db, targetType, targetID, time.Now())
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "target_type"}, {Name: "target_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"score", "decayed_average", "effective_weight", "review_count", "platform_mean", "refreshed_at",
+		}),
+	}).Create(&score).Error
+}
+
+// RefreshStaleRatingScores 重算超过 Rating.RefreshAfter 未刷新、或计算时的平台均分已偏离当前均分超过
+// platformMeanDrift 的排名分（每轮至多 rankingBatchSize 条），使时间衰减与平台均分的变化反映到排名中
+func RefreshStaleRatingScores(now time.Time) (int64, error) {
+	refreshAfter := config.GetConfig().Rating.RefreshAfter
+	if refreshAfter <= 0 {
+		return 0, nil
+	}
+	db := config.GetDB()
+
+	query := db.Where("refreshed_at <= ?", now.Add(-refreshAfter))
+	for _, targetType := range []models.ReviewTargetType{models.ReviewTargetProvider, models.ReviewTargetStudio} {
+		mean := platformMean(db, targetType)
+		query = query.Or("(target_type = ? AND ABS(platform_mean - ?) > ?)", targetType, mean, platformMeanDrift)
+	}
+
+	var stale []models.RatingScore
+	if err := query.Order("refreshed_at ASC").Limit(rankingBatchSize).Find(&stale).Error; err != nil {
+		return 0, err
+	}
+
+	var refreshed int64
+	for _, s := range stale {
+		if err := refreshRatingScore(db, s.TargetType, s.TargetID); err != nil {
+			return refreshed, err
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
+// BackfillRatingScores 为已有公开评价但还没有排名分的对象补算排名分（每轮至多 rankingBatchSize 个），
+// 覆盖排名分表上线前就已存在的评价，否则这些对象只能按平台均分参与排序
+func BackfillRatingScores(now time.Time) (int64, error) {
+	db := config.GetDB()
+
+	var missing []struct {
+		TargetType models.ReviewTargetType
+		TargetID   uint
+	}
+	if err := db.Model(&models.Review{}).
+		Joins("LEFT JOIN rating_scores ON rating_scores.target_type = reviews.target_type AND rating_scores.target_id = reviews.target_id").
+		Where("reviews.status = ? AND rating_scores.id IS NULL", models.ReviewStatusVisible).
+		Distinct("reviews.target_type", "reviews.target_id").
+		Limit(rankingBatchSize).Scan(&missing).Error; err != nil {
+		return 0, err
+	}
+
+	var filled int64
+	for _, m := range missing {
+		if err := refreshRatingScore(db, m.TargetType, m.TargetID); err != nil {
+			return filled, err
+		}
+		filled++
+	}
+	return filled, nil
+}
+
+// rankingOrder 列表按排名分排序：LEFT JOIN 排名分表，未有评分的对象按平台均分（先验）参与排序
+func rankingOrder(query *gorm.DB, table string, targetType models.ReviewTargetType) *gorm.DB {
+	mean := platformMean(config.GetDB(), targetType)
+	return query.Select(table+".*").
+		Joins("LEFT JOIN rating_scores ON rating_scores.target_type = ? AND rating_scores.target_id = "+table+".id", targetType).
+		Order(fmt.Sprintf("COALESCE(rating_scores.score, %f) DESC", mean)).
+		Order(table + ".id ASC")
+}
@@ -1,12 +1,18 @@
 package controllers
 
 import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
 	"companion-platform-backend/config"
 	"companion-platform-backend/middleware"
 	"companion-platform-backend/models"
 	"companion-platform-backend/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type ReviewController struct{}
@@ -17,11 +23,24 @@ type CreateReviewRequest struct {
 	TargetID     uint                    `json:"target_id" binding:"required"`
 	Rating       int                     `json:"rating" binding:"required,min=1,max=5"`
 	Content      string                  `json:"content"`
-	Tags         string                  `json:"tags"`
+	Tags         []string                `json:"tags"` // 标签名称，须在标签词表中（最多 5 个）
 	IsAnonymous  bool                    `json:"is_anonymous"`
 	PlayRecordID *uint                   `json:"play_record_id"`
 }
 
+// UpdateReviewRequest 玩家在修改时限内修改评价（整体替换评分、内容与标签）
+type UpdateReviewRequest struct {
+	Rating      int      `json:"rating" binding:"required,min=1,max=5"`
+	Content     string   `json:"content"`
+	Tags        []string `json:"tags"`
+	IsAnonymous *bool    `json:"is_anonymous"`
+}
+
+// ReviewReplyRequest 回复评价
+type ReviewReplyRequest struct {
+	Content string `json:"content" binding:"required,max=1000"`
+}
+
 // Create 玩家创建评价
 func (rc *ReviewController) Create(c *gin.Context) {
 	userID, err := middleware.GetCurrentUserID(c)
@@ -53,18 +72,69 @@ func (rc *ReviewController) Create(c *gin.Context) {
 		}
 	}
 
+	// 关联游玩记录：须为本人已完成的记录、与评价对象一致，且尚未被评价过
+	verified := false
+	if req.PlayRecordID != nil {
+		var record models.PlayRecord
+		if err := db.First(&record, *req.PlayRecordID).Error; err != nil {
+			utils.BadRequest(c, "关联的游玩记录不存在")
+			return
+		}
+		if record.PlayerID != userID {
+			utils.Forbidden(c, "只能评价自己参与的陪玩")
+			return
+		}
+		if record.Status != models.PlayStatusCompleted {
+			utils.BadRequest(c, "只有已完成的陪玩可以评价")
+			return
+		}
+		if (req.TargetType == models.ReviewTargetProvider && record.ProviderID != req.TargetID) ||
+			(req.TargetType == models.ReviewTargetStudio && record.StudioID != req.TargetID) {
+			utils.BadRequest(c, "评价对象与游玩记录不符")
+			return
+		}
+		var existing int64
+		db.Unscoped().Model(&models.Review{}).Where("play_record_id = ?", record.ID).Count(&existing)
+		if existing > 0 {
+			utils.BadRequest(c, "该局陪玩已评价过")
+			return
+		}
+		verified = true
+	}
+
+	tags, err := resolveReviewTags(db, req.Tags)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
 	review := models.Review{
 		PlayerID:     userID,
 		TargetType:   req.TargetType,
 		TargetID:     req.TargetID,
 		Rating:       req.Rating,
 		Content:      req.Content,
-		Tags:         req.Tags,
+		Tags:         tags,
 		IsAnonymous:  req.IsAnonymous,
 		PlayRecordID: req.PlayRecordID,
+		Verified:     verified,
 	}
 
-	if err := db.Create(&review).Error; err != nil {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&review).Error; err != nil {
+			return err
+		}
+		return refreshRatingScore(tx, review.TargetType, review.TargetID)
+	}); err != nil {
+		// 并发提交同一局的评价时，唯一索引拒绝后到的一条
+		if review.PlayRecordID != nil {
+			var existing int64
+			db.Unscoped().Model(&models.Review{}).Where("play_record_id = ?", *review.PlayRecordID).Count(&existing)
+			if existing > 0 {
+				utils.BadRequest(c, "该局陪玩已评价过")
+				return
+			}
+		}
 		utils.InternalServerError(c, "Failed to create review")
 		return
 	}
@@ -72,7 +142,136 @@ func (rc *ReviewController) Create(c *gin.Context) {
 	utils.SuccessWithMessage(c, "评价已提交", review)
 }
 
-// ListByTarget 查看某个对象（服务者/工作室）的评价列表（公开）
+// Update 玩家修改自己的评价：仅限 Review.EditWindow 内、未被隐藏的评价；旧版本存入修改历史，
+// 汇总与排名分始终按最新版本计算
+func (rc *ReviewController) Update(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	reviewID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid review ID")
+		return
+	}
+
+	var req UpdateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var review models.Review
+	if err := db.Preload("Tags").First(&review, reviewID).Error; err != nil {
+		utils.NotFound(c, "评价不存在")
+		return
+	}
+	if review.PlayerID != userID {
+		utils.Forbidden(c, "只能修改自己的评价")
+		return
+	}
+	if review.Status != models.ReviewStatusVisible {
+		utils.BadRequest(c, "评价已被隐藏，不能修改")
+		return
+	}
+	if time.Since(review.CreatedAt) > config.GetConfig().Review.EditWindow {
+		utils.BadRequest(c, "已超过评价修改时限")
+		return
+	}
+
+	tags, err := resolveReviewTags(db, req.Tags)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	oldTags := make([]string, 0, len(review.Tags))
+	for _, t := range review.Tags {
+		oldTags = append(oldTags, t.Name)
+	}
+	revision := models.ReviewRevision{
+		ReviewID:    review.ID,
+		Rating:      review.Rating,
+		Content:     review.Content,
+		TagNames:    strings.Join(oldTags, ","),
+		IsAnonymous: review.IsAnonymous,
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"rating":     req.Rating,
+		"content":    req.Content,
+		"edit_count": gorm.Expr("edit_count + 1"),
+		"edited_at":  &now,
+	}
+	if req.IsAnonymous != nil {
+		updates["is_anonymous"] = *req.IsAnonymous
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&review).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&review).Association("Tags").Replace(tags); err != nil {
+			return err
+		}
+		return refreshRatingScore(tx, review.TargetType, review.TargetID)
+	}); err != nil {
+		utils.InternalServerError(c, "修改评价失败")
+		return
+	}
+
+	db.Preload("Tags").First(&review, reviewID)
+	utils.SuccessWithMessage(c, "评价已修改", review)
+}
+
+// Revisions 评价的修改历史（评价作者查看）
+func (rc *ReviewController) Revisions(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	reviewID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid review ID")
+		return
+	}
+
+	db := config.GetDB()
+	var review models.Review
+	if err := db.First(&review, reviewID).Error; err != nil {
+		utils.NotFound(c, "评价不存在")
+		return
+	}
+	if review.PlayerID != userID {
+		utils.Forbidden(c, "只能查看自己评价的修改历史")
+		return
+	}
+
+	var revisions []models.ReviewRevision
+	db.Where("review_id = ?", review.ID).Order("created_at ASC, id ASC").Find(&revisions)
+	utils.Success(c, revisions)
+}
+
+// reviewSortOrders 评价列表排序方式（sort 参数），默认 newest
+var reviewSortOrders = map[string]string{
+	"newest":      "created_at DESC, id DESC",
+	"helpful":     "helpful_count DESC, created_at DESC, id DESC",
+	"rating_desc": "rating DESC, created_at DESC, id DESC",
+	"rating_asc":  "rating ASC, created_at DESC, id DESC",
+}
+
+// ListByTarget 查看某个对象（服务者/工作室）的评价列表（公开）。
+// 支持 sort=newest|helpful|rating_desc|rating_asc，rating=1-5 按星级筛选，
+// has_content=1 只看有文字内容的评价，verified=1 只看已验证评价
 func (rc *ReviewController) ListByTarget(c *gin.Context) {
 	targetType := c.Query("target_type")
 	targetID := c.Query("target_id")
@@ -84,36 +283,149 @@ func (rc *ReviewController) ListByTarget(c *gin.Context) {
 	db := config.GetDB()
 	page, pageSize, offset := paginate(c)
 
-	query := db.Model(&models.Review{}).Where("target_type = ? AND target_id = ?", targetType, targetID)
+	query := db.Model(&models.Review{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, models.ReviewStatusVisible)
+	if c.Query("verified") == "1" {
+		query = query.Where("verified = ?", true)
+	}
+	if r := c.Query("rating"); r != "" {
+		rating, err := strconv.Atoi(r)
+		if err != nil || rating < 1 || rating > 5 {
+			utils.BadRequest(c, "rating 须为 1-5")
+			return
+		}
+		query = query.Where("rating = ?", rating)
+	}
+	if c.Query("has_content") == "1" {
+		query = query.Where("content IS NOT NULL AND TRIM(content) <> ''")
+	}
+	order, ok := reviewSortOrders[c.DefaultQuery("sort", "newest")]
+	if !ok {
+		utils.BadRequest(c, "不支持的排序方式")
+		return
+	}
 
 	var total int64
 	query.Count(&total)
 
 	var reviews []models.Review
-	if err := query.Preload("Player").Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&reviews).Error; err != nil {
+	if err := query.Preload("Player").Preload("Reply").Preload("Tags").
+		Order(order).Offset(offset).Limit(pageSize).Find(&reviews).Error; err != nil {
 		utils.InternalServerError(c, "Failed to get reviews")
 		return
 	}
 
-	// 匿名评价隐藏玩家身份
+	maskAnonymous(reviews)
+	utils.PageSuccess(c, reviews, total, page, pageSize)
+}
+
+// MarkHelpful 登录用户把一条公开评价标记为「有帮助」（每人每条一票，不能给自己的评价投票）
+func (rc *ReviewController) MarkHelpful(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	reviewID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid review ID")
+		return
+	}
+
+	db := config.GetDB()
+	var review models.Review
+	if err := db.Where("status = ?", models.ReviewStatusVisible).First(&review, reviewID).Error; err != nil {
+		utils.NotFound(c, "评价不存在")
+		return
+	}
+	if review.PlayerID == userID {
+		utils.BadRequest(c, "不能给自己的评价投票")
+		return
+	}
+
+	var existing int64
+	db.Model(&models.ReviewHelpfulVote{}).Where("review_id = ? AND user_id = ?", review.ID, userID).Count(&existing)
+	if existing > 0 {
+		utils.BadRequest(c, "你已投过票")
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.ReviewHelpfulVote{ReviewID: review.ID, UserID: userID}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Review{}).Where("id = ?", review.ID).
+			UpdateColumn("helpful_count", gorm.Expr("helpful_count + 1")).Error
+	}); err != nil {
+		utils.InternalServerError(c, "投票失败")
+		return
+	}
+
+	db.First(&review, review.ID)
+	utils.SuccessWithMessage(c, "已标记为有帮助", gin.H{"review_id": review.ID, "helpful_count": review.HelpfulCount})
+}
+
+// UnmarkHelpful 撤销自己的「有帮助」投票
+func (rc *ReviewController) UnmarkHelpful(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	reviewID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid review ID")
+		return
+	}
+
+	db := config.GetDB()
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("review_id = ? AND user_id = ?", reviewID, userID).Delete(&models.ReviewHelpfulVote{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Unscoped().Model(&models.Review{}).Where("id = ? AND helpful_count > 0", reviewID).
+			UpdateColumn("helpful_count", gorm.Expr("helpful_count - 1")).Error
+	})
+	if txErr != nil {
+		if errors.Is(txErr, gorm.ErrRecordNotFound) {
+			utils.NotFound(c, "你未给该评价投票")
+		} else {
+			utils.InternalServerError(c, "撤销投票失败")
+		}
+		return
+	}
+
+	var review models.Review
+	db.Unscoped().First(&review, reviewID)
+	utils.SuccessWithMessage(c, "已撤销投票", gin.H{"review_id": review.ID, "helpful_count": review.HelpfulCount})
+}
+
+// maskAnonymous 匿名评价隐藏玩家身份
+func maskAnonymous(reviews []models.Review) {
 	for i := range reviews {
 		if reviews[i].IsAnonymous {
 			reviews[i].Player = models.User{Nickname: "匿名用户"}
 			reviews[i].PlayerID = 0
 		}
 	}
-
-	utils.PageSuccess(c, reviews, total, page, pageSize)
 }
 
 // ReviewSummary 评分汇总
 type ReviewSummary struct {
-	AverageRating float64       `json:"average_rating"`
-	Count         int64         `json:"count"`
-	Distribution  map[int]int64 `json:"distribution"` // 各星级数量
+	AverageRating float64          `json:"average_rating"`
+	Count         int64            `json:"count"`
+	Distribution  map[int]int64    `json:"distribution"`   // 各星级数量
+	VerifiedCount int64            `json:"verified_count"` // 其中已验证评价数量
+	Tags          []ReviewTagCount `json:"tags"`           // 各标签选用次数（降序）
 }
 
-// Summary 某个对象的评分汇总（平均分、数量、星级分布）
+// Summary 某个对象的评分汇总（平均分、数量、星级分布；verified=1 只统计已验证评价）
 func (rc *ReviewController) Summary(c *gin.Context) {
 	targetType := c.Query("target_type")
 	targetID := c.Query("target_id")
@@ -128,10 +440,13 @@ func (rc *ReviewController) Summary(c *gin.Context) {
 		Rating int
 		Cnt    int64
 	}
-	if err := db.Model(&models.Review{}).
+	query := db.Model(&models.Review{}).
 		Select("rating, COUNT(*) as cnt").
-		Where("target_type = ? AND target_id = ?", targetType, targetID).
-		Group("rating").Scan(&rows).Error; err != nil {
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, models.ReviewStatusVisible)
+	if c.Query("verified") == "1" {
+		query = query.Where("verified = ?", true)
+	}
+	if err := query.Group("rating").Scan(&rows).Error; err != nil {
 		utils.InternalServerError(c, "Failed to get review summary")
 		return
 	}
@@ -147,6 +462,12 @@ func (rc *ReviewController) Summary(c *gin.Context) {
 	if count > 0 {
 		summary.AverageRating = float64(weighted) / float64(count)
 	}
+	db.Model(&models.Review{}).
+		Where("target_type = ? AND target_id = ? AND status = ? AND verified = ?",
+			targetType, targetID, models.ReviewStatusVisible, true).
+		Count(&summary.VerifiedCount)
+	id, _ := parseUintParam(targetID)
+	summary.Tags = reviewTagCounts(db, models.ReviewTargetType(targetType), id, c.Query("verified") == "1")
 
 	utils.Success(c, summary)
 }
@@ -167,10 +488,138 @@ func (rc *ReviewController) ListMine(c *gin.Context) {
 	query.Count(&total)
 
 	var reviews []models.Review
-	if err := query.Preload("PlayRecord").Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&reviews).Error; err != nil {
+	if err := query.Preload("PlayRecord").Preload("Tags").Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&reviews).Error; err != nil {
 		utils.InternalServerError(c, "Failed to get reviews")
 		return
 	}
 
 	utils.PageSuccess(c, reviews, total, page, pageSize)
 }
+
+// canReply 是否可代表被评价方回复：服务者本人，或工作室所有者 / 有评价回复权限的员工
+func canReply(db *gorm.DB, review *models.Review, userID uint) bool {
+	if review.TargetType == models.ReviewTargetProvider {
+		return review.TargetID == userID
+	}
+	var studio models.Studio
+	return db.First(&studio, review.TargetID).Error == nil && hasStudioPermission(db, &studio, userID, models.PermStudioReviews)
+}
+
+// loadRepliable 加载评价并校验当前用户可以回复
+func (rc *ReviewController) loadRepliable(c *gin.Context) (*models.Review, uint, bool) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return nil, 0, false
+	}
+
+	reviewID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid review ID")
+		return nil, 0, false
+	}
+
+	db := config.GetDB()
+	var review models.Review
+	if err := db.Preload("Reply").First(&review, reviewID).Error; err != nil {
+		utils.NotFound(c, "评价不存在")
+		return nil, 0, false
+	}
+	if !canReply(db, &review, userID) {
+		utils.Forbidden(c, "只有被评价的服务者或工作室可以回复")
+		return nil, 0, false
+	}
+	return &review, userID, true
+}
+
+// Reply 被评价方发表公开回复（每条评价一条）
+func (rc *ReviewController) Reply(c *gin.Context) {
+	review, userID, ok := rc.loadRepliable(c)
+	if !ok {
+		return
+	}
+
+	var req ReviewReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if review.Reply != nil {
+		utils.BadRequest(c, "该评价已回复，请修改原回复")
+		return
+	}
+
+	reply := models.ReviewReply{ReviewID: review.ID, AuthorID: userID, Content: req.Content}
+	if err := config.GetDB().Create(&reply).Error; err != nil {
+		utils.BadRequest(c, "该评价已回复，请修改原回复")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "回复已发布", reply)
+}
+
+// UpdateReply 修改回复
+func (rc *ReviewController) UpdateReply(c *gin.Context) {
+	review, userID, ok := rc.loadRepliable(c)
+	if !ok {
+		return
+	}
+
+	var req ReviewReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if review.Reply == nil {
+		utils.NotFound(c, "回复不存在")
+		return
+	}
+
+	reply := review.Reply
+	if err := config.GetDB().Model(reply).Updates(map[string]interface{}{
+		"content":   req.Content,
+		"author_id": userID,
+	}).Error; err != nil {
+		utils.InternalServerError(c, "修改回复失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "回复已修改", reply)
+}
+
+// DeleteReply 删除回复
+func (rc *ReviewController) DeleteReply(c *gin.Context) {
+	review, _, ok := rc.loadRepliable(c)
+	if !ok {
+		return
+	}
+	if review.Reply == nil {
+		utils.NotFound(c, "回复不存在")
+		return
+	}
+
+	if err := config.GetDB().Delete(review.Reply).Error; err != nil {
+		utils.InternalServerError(c, "删除回复失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "回复已删除", nil)
+}
+
+// unansweredReviews 被评价方尚未回复的评价（最近 limit 条）及总数
+func unansweredReviews(db *gorm.DB, targetType models.ReviewTargetType, targetID uint, limit int) ([]models.Review, int64) {
+	query := db.Model(&models.Review{}).
+		Joins("LEFT JOIN review_replies ON review_replies.review_id = reviews.id").
+		Where("reviews.target_type = ? AND reviews.target_id = ? AND reviews.status = ? AND review_replies.id IS NULL",
+			targetType, targetID, models.ReviewStatusVisible)
+
+	var total int64
+	query.Count(&total)
+
+	reviews := []models.Review{}
+	if limit > 0 {
+		query.Preload("Player").Order("reviews.created_at DESC").Limit(limit).Find(&reviews)
+		maskAnonymous(reviews)
+	}
+	return reviews, total
+}
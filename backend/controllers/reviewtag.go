@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/models"
+	"companion-platform-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReviewTagController struct{}
+
+// maxReviewTags 单条评价最多可选的标签数
+const maxReviewTags = 5
+
+// CreateReviewTagRequest 新增评价标签
+type CreateReviewTagRequest struct {
+	Name      string             `json:"name" binding:"required,max=20"`
+	Polarity  models.TagPolarity `json:"polarity" binding:"required,oneof=positive negative"`
+	SortOrder int                `json:"sort_order"`
+}
+
+// UpdateReviewTagRequest 修改评价标签
+type UpdateReviewTagRequest struct {
+	Name      string             `json:"name" binding:"required,max=20"`
+	Polarity  models.TagPolarity `json:"polarity" binding:"required,oneof=positive negative"`
+	SortOrder int                `json:"sort_order"`
+	IsActive  *bool              `json:"is_active"`
+}
+
+// ReviewTagCount 单个标签被选用的次数
+type ReviewTagCount struct {
+	TagID    uint               `json:"tag_id"`
+	Name     string             `json:"name"`
+	Polarity models.TagPolarity `json:"polarity"`
+	Count    int64              `json:"count"`
+}
+
+// resolveReviewTags 按名称在词表中解析启用中的标签（去重），未知或已停用的名称返回错误
+func resolveReviewTags(db *gorm.DB, names []string) ([]models.ReviewTag, error) {
+	seen := map[string]bool{}
+	var wanted []string
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n != "" && !seen[n] {
+			seen[n] = true
+			wanted = append(wanted, n)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+	if len(wanted) > maxReviewTags {
+		return nil, errors.New("标签最多选择 5 个")
+	}
+
+	var tags []models.ReviewTag
+	if err := db.Where("name IN ? AND is_active = ?", wanted, true).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	if len(tags) != len(wanted) {
+		found := map[string]bool{}
+		for _, t := range tags {
+			found[t.Name] = true
+		}
+		for _, n := range wanted {
+			if !found[n] {
+				return nil, errors.New("未知标签：" + n)
+			}
+		}
+	}
+	return tags, nil
+}
+
+// reviewTagCounts 某对象公开评价中各标签的选用次数（按次数降序），verifiedOnly 时只统计已验证评价
+func reviewTagCounts(db *gorm.DB, targetType models.ReviewTargetType, targetID uint, verifiedOnly bool) []ReviewTagCount {
+	query := db.Table("review_tag_links").
+		Select("review_tags.id as tag_id, review_tags.name, review_tags.polarity, COUNT(*) as count").
+		Joins("JOIN reviews ON reviews.id = review_tag_links.review_id").
+		Joins("JOIN review_tags ON review_tags.id = review_tag_links.review_tag_id").
+		Where("reviews.target_type = ? AND reviews.target_id = ? AND reviews.status = ? AND reviews.deleted_at IS NULL",
+			targetType, targetID, models.ReviewStatusVisible)
+	if verifiedOnly {
+		query = query.Where("reviews.verified = ?", true)
+	}
+
+	rows := []ReviewTagCount{}
+	query.Group("review_tags.id, review_tags.name, review_tags.polarity").
+		Order("count DESC, review_tags.id ASC").Scan(&rows)
+	return rows
+}
+
+// List 评价标签词表（公开，仅启用中的标签，按倾向分组）
+func (tc *ReviewTagController) List(c *gin.Context) {
+	var tags []models.ReviewTag
+	if err := config.GetDB().Where("is_active = ?", true).
+		Order("sort_order ASC, id ASC").Find(&tags).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get review tags")
+		return
+	}
+
+	groups := map[models.TagPolarity][]models.ReviewTag{
+		models.TagPositive: {},
+		models.TagNegative: {},
+	}
+	for _, t := range tags {
+		groups[t.Polarity] = append(groups[t.Polarity], t)
+	}
+
+	utils.Success(c, groups)
+}
+
+// Create 新增评价标签
+func (tc *ReviewTagController) Create(c *gin.Context) {
+	var req CreateReviewTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	name := strings.TrimSpace(req.Name)
+	var count int64
+	db.Model(&models.ReviewTag{}).Where("name = ?", name).Count(&count)
+	if count > 0 {
+		utils.BadRequest(c, "标签已存在")
+		return
+	}
+
+	tag := models.ReviewTag{Name: name, Polarity: req.Polarity, SortOrder: req.SortOrder, IsActive: true}
+	if err := db.Create(&tag).Error; err != nil {
+		utils.InternalServerError(c, "Failed to create review tag")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "标签已创建", tag)
+}
+
+// Update 修改评价标签（改名、调整倾向或停用）
+func (tc *ReviewTagController) Update(c *gin.Context) {
+	tagID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid tag ID")
+		return
+	}
+
+	var req UpdateReviewTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var tag models.ReviewTag
+	if err := db.First(&tag, tagID).Error; err != nil {
+		utils.NotFound(c, "标签不存在")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	var count int64
+	db.Model(&models.ReviewTag{}).Where("name = ? AND id <> ?", name, tag.ID).Count(&count)
+	if count > 0 {
+		utils.BadRequest(c, "标签已存在")
+		return
+	}
+
+	updates := map[string]interface{}{
+		"name":       name,
+		"polarity":   req.Polarity,
+		"sort_order": req.SortOrder,
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if err := db.Model(&tag).Updates(updates).Error; err != nil {
+		utils.InternalServerError(c, "Failed to update review tag")
+		return
+	}
+
+	db.First(&tag, tagID)
+	utils.SuccessWithMessage(c, "标签已更新", tag)
+}
+
+// ReviewTagMigrationReport 历史标签迁移结果
+type ReviewTagMigrationReport struct {
+	Reviews   int64    `json:"reviews"`   // 写入了标签关联的评价数
+	Unmatched []string `json:"unmatched"` // 词表中不存在的历史标签
+}
+
+// MigrateLegacyReviewTags 把历史评价的自由文本标签（JSON 数组字符串，兼容逗号分隔）按名称关联到标签词表。
+// 只处理尚无标签关联的评价；词表中不存在的标签原样保留在旧字段并列入报告。
+func MigrateLegacyReviewTags(db *gorm.DB) (*ReviewTagMigrationReport, error) {
+	var reviews []models.Review
+	if err := db.Unscoped().
+		Where("tags <> '' AND NOT EXISTS (SELECT 1 FROM review_tag_links WHERE review_tag_links.review_id = reviews.id)").
+		Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+
+	var vocabulary []models.ReviewTag
+	if err := db.Find(&vocabulary).Error; err != nil {
+		return nil, err
+	}
+	byName := map[string]models.ReviewTag{}
+	for _, t := range vocabulary {
+		byName[t.Name] = t
+	}
+
+	report := &ReviewTagMigrationReport{Unmatched: []string{}}
+	unmatched := map[string]bool{}
+	for i := range reviews {
+		review := &reviews[i]
+		var names []string
+		if err := json.Unmarshal([]byte(review.LegacyTags), &names); err != nil {
+			names = strings.Split(review.LegacyTags, ",")
+		}
+
+		var tags []models.ReviewTag
+		linked := map[uint]bool{}
+		for _, n := range names {
+			n = strings.TrimSpace(n)
+			if tag, ok := byName[n]; ok {
+				if !linked[tag.ID] {
+					linked[tag.ID] = true
+					tags = append(tags, tag)
+				}
+			} else if n != "" && !unmatched[n] {
+				unmatched[n] = true
+				report.Unmatched = append(report.Unmatched, n)
+			}
+		}
+		if len(tags) == 0 {
+			continue
+		}
+		if err := db.Model(review).Association("Tags").Append(tags); err != nil {
+			return report, err
+		}
+		report.Reviews++
+	}
+	return report, nil
+}
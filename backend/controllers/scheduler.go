@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// StartScheduler 启动后台定时任务，每隔 interval 执行一轮 RunScheduledJobs。
+// 返回的 stop 用于优雅退出。
+func StartScheduler(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				RunScheduledJobs(now)
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// RunScheduledJobs 执行一轮全部定时任务。各任务相互独立，单个失败只记日志。
+func RunScheduledJobs(now time.Time) {
+	if n, err := AutoConfirmExpired(now); err != nil {
+		log.Printf("scheduler: auto-confirm failed: %v", err)
+	} else if n > 0 {
+		log.Printf("scheduler: auto-confirmed %d play records", n)
+	}
+	if n, err := CloseStaleActive(now); err != nil {
+		log.Printf("scheduler: close stale records failed: %v", err)
+	} else if n > 0 {
+		log.Printf("scheduler: handled %d stale play records", n)
+	}
+	if n, err := FlagIdleActive(now); err != nil {
+		log.Printf("scheduler: flag idle records failed: %v", err)
+	} else if n > 0 {
+		log.Printf("scheduler: flagged %d idle play records", n)
+	}
+	if n, err := BackfillRatingScores(now); err != nil {
+		log.Printf("scheduler: backfill rating scores failed: %v", err)
+	} else if n > 0 {
+		log.Printf("scheduler: backfilled %d rating scores", n)
+	}
+	if n, err := RefreshStaleRatingScores(now); err != nil {
+		log.Printf("scheduler: refresh rating scores failed: %v", err)
+	} else if n > 0 {
+		log.Printf("scheduler: refreshed %d rating scores", n)
+	}
+}
+
+// AutoConfirmExpired 将确认窗口已过、玩家仍未操作的已完成记录标记为已确认
+func AutoConfirmExpired(now time.Time) (int64, error) {
+	res := config.GetDB().Model(&models.PlayRecord{}).
+		Where("status = ? AND confirm_status = ? AND confirm_deadline <= ?",
+			models.PlayStatusCompleted, models.ConfirmStatusPending, now).
+		Updates(map[string]interface{}{
+			"confirm_status": models.ConfirmStatusConfirmed,
+			"confirmed_at":   now,
+		})
+	return res.RowsAffected, res.Error
+}
+
+// CloseStaleActive 处理进行中超过 Play.MaxActive 的记录：按 Play.StaleAction 自动取消或仅标记，
+// 原因追加到记录描述，并通知服务者。每条记录只处理一次（以 stale_at 为准）。
+func CloseStaleActive(now time.Time) (int64, error) {
+	cfg := config.GetConfig().Play
+	if cfg.MaxActive <= 0 {
+		return 0, nil
+	}
+	db := config.GetDB()
+
+	var records []models.PlayRecord
+	if err := db.Where("status = ? AND stale_at IS NULL AND start_time <= ?",
+		models.PlayStatusActive, now.Add(-cfg.MaxActive)).Find(&records).Error; err != nil {
+		return 0, err
+	}
+
+	cancel := cfg.StaleAction == "cancel"
+	var handled int64
+	for i := range records {
+		record := &records[i]
+		reason := fmt.Sprintf("进行中超过 %s 未结束", cfg.MaxActive)
+		updates := map[string]interface{}{"stale_at": &now}
+		title := "陪玩长时间未结束"
+		if cancel {
+			reason += "，已自动取消"
+			updates["status"] = models.PlayStatusCancelled
+			updates["end_time"] = &now
+			title = "陪玩已被自动取消"
+		} else {
+			reason += "，请及时完成或取消"
+		}
+		updates["description"] = appendSystemNote(record.Description, reason)
+
+		applied := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// 条件更新：避免与服务者并发的完成/取消操作相互覆盖
+			res := tx.Model(&models.PlayRecord{}).
+				Where("id = ? AND status = ? AND stale_at IS NULL", record.ID, models.PlayStatusActive).
+				Updates(updates)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			applied = true
+			// 订阅场次自动取消时退还该场预付款并扣减场次，与服务者手动取消一致
+			if cancel && record.SubscriptionID != nil {
+				if err := dropSubscriptionSessionTx(tx, *record.SubscriptionID, 0); err != nil {
+					return err
+				}
+			}
+			return notify(tx, record.ProviderID, models.NotificationPlayRecordStale, title,
+				fmt.Sprintf("%s（开始于 %s）%s", recordDesc(record), record.StartTime.Format("2006-01-02 15:04"), reason),
+				record.ID)
+		})
+		if err != nil {
+			return handled, err
+		}
+		if applied {
+			handled++
+		}
+	}
+	return handled, nil
+}
+
+// FlagIdleActive 标记已启用活动监测（发过事件或心跳）、但超过 Play.IdleTimeout 无活动的进行中记录，
+// 并通知服务者。标记在下一次事件或心跳时清除，因此同一段静默只通知一次。
+func FlagIdleActive(now time.Time) (int64, error) {
+	timeout := config.GetConfig().Play.IdleTimeout
+	if timeout <= 0 {
+		return 0, nil
+	}
+	db := config.GetDB()
+
+	var records []models.PlayRecord
+	if err := db.Where("status = ? AND idle_at IS NULL AND last_activity_at IS NOT NULL AND last_activity_at <= ?",
+		models.PlayStatusActive, now.Add(-timeout)).Find(&records).Error; err != nil {
+		return 0, err
+	}
+
+	var flagged int64
+	for i := range records {
+		record := &records[i]
+		applied := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Model(&models.PlayRecord{}).
+				Where("id = ? AND status = ? AND idle_at IS NULL AND last_activity_at <= ?",
+					record.ID, models.PlayStatusActive, now.Add(-timeout)).
+				Update("idle_at", &now)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			applied = true
+			return notify(tx, record.ProviderID, models.NotificationPlayRecordIdle, "陪玩长时间无动态",
+				fmt.Sprintf("%s 已超过 %s 没有事件或心跳", recordDesc(record), timeout), record.ID)
+		})
+		if err != nil {
+			return flagged, err
+		}
+		if applied {
+			flagged++
+		}
+	}
+	return flagged, nil
+}
+
+// appendSystemNote 在记录描述末尾追加一行系统备注
+func appendSystemNote(desc, note string) string {
+	line := "[系统] " + note
+	if desc == "" {
+		return line
+	}
+	return desc + "\n" + line
+}
@@ -0,0 +1,344 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/middleware"
+	"companion-platform-backend/models"
+	"companion-platform-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type StudioStaffController struct{}
+
+// AddStaffRequest 添加员工
+type AddStaffRequest struct {
+	Username string           `json:"username" binding:"required"`
+	Role     models.StaffRole `json:"role" binding:"required,oneof=manager finance reviewer"`
+}
+
+// UpdateStaffRequest 调整员工角色
+type UpdateStaffRequest struct {
+	Role models.StaffRole `json:"role" binding:"required,oneof=manager finance reviewer"`
+}
+
+// studioOwnerAccess 所有者在 studioAccessRole 中的身份标记
+const studioOwnerAccess = "owner"
+
+// studioAccessTTL 用户-工作室身份的缓存时长（员工变动时主动失效）
+const studioAccessTTL = 5 * time.Minute
+
+func studioAccessKey(studioID, userID uint) string {
+	return fmt.Sprintf("studio_access:%d:%d", studioID, userID)
+}
+
+// invalidateStudioAccess 员工变动后清除该用户在该工作室的身份缓存
+func invalidateStudioAccess(studioID, userID uint) {
+	utils.DeleteCache(studioAccessKey(studioID, userID))
+}
+
+// studioAccessRole 用户在工作室中的身份：owner、员工角色，或空（无关）。员工身份短期缓存
+func studioAccessRole(db *gorm.DB, studio *models.Studio, userID uint) string {
+	if studio.OwnerID == userID {
+		return studioOwnerAccess
+	}
+	key := studioAccessKey(studio.ID, userID)
+	if v, ok := utils.GetCacheValue(key); ok {
+		if role, ok := v.(string); ok {
+			return role
+		}
+	}
+	role := ""
+	var staff models.StudioStaff
+	if db.Where("studio_id = ? AND user_id = ?", studio.ID, userID).First(&staff).Error == nil {
+		role = string(staff.Role)
+	}
+	utils.SetCache(key, role, studioAccessTTL)
+	return role
+}
+
+// hasStudioPermission 用户在工作室中是否拥有某项权限：所有者拥有全部权限，员工按角色
+func hasStudioPermission(db *gorm.DB, studio *models.Studio, userID uint, perm models.StudioPermission) bool {
+	role := studioAccessRole(db, studio, userID)
+	if role == studioOwnerAccess {
+		return true
+	}
+	for _, p := range models.StaffRolePermissions[models.StaffRole(role)] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeStudio 加载路由 :id 指定的工作室，并校验当前用户拥有 perm 权限（失败时已写出响应）
+func authorizeStudio(c *gin.Context, perm models.StudioPermission) (uint, *models.Studio, bool) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return 0, nil, false
+	}
+	studioID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid studio ID")
+		return 0, nil, false
+	}
+	db := config.GetDB()
+	var studio models.Studio
+	if err := db.First(&studio, studioID).Error; err != nil {
+		utils.NotFound(c, "Studio not found")
+		return 0, nil, false
+	}
+	if !hasStudioPermission(db, &studio, userID, perm) {
+		utils.Forbidden(c, "无权执行该工作室操作")
+		return 0, nil, false
+	}
+	return userID, &studio, true
+}
+
+// currentStudio 不带工作室 ID 的接口所操作的工作室：当前用户拥有的第一个工作室，其次是具备 perm 权限的任职工作室
+func currentStudio(db *gorm.DB, userID uint, perm models.StudioPermission) (*models.Studio, error) {
+	var studio models.Studio
+	if err := db.Where("owner_id = ?", userID).Order("id ASC").First(&studio).Error; err == nil {
+		return &studio, nil
+	}
+	var staff []models.StudioStaff
+	if err := db.Where("user_id = ?", userID).Preload("Studio").Order("id ASC").Find(&staff).Error; err != nil {
+		return nil, err
+	}
+	for i := range staff {
+		if staff[i].Studio.ID != 0 && hasStudioPermission(db, &staff[i].Studio, userID, perm) {
+			return &staff[i].Studio, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// StudioHeader 多工作室账号用于选择当前操作工作室的请求头
+const StudioHeader = "X-Studio-ID"
+
+// selectStudio 解析本次请求作用的工作室并校验 perm 权限（失败时已写出响应）。
+// 优先级：路由 :id → 显式传入的 studioID（如请求体 studio_id）→ X-Studio-ID 请求头 → currentStudio 默认工作室
+func selectStudio(c *gin.Context, db *gorm.DB, userID uint, perm models.StudioPermission, studioID uint) (*models.Studio, bool) {
+	if id := c.Param("id"); id != "" {
+		parsed, err := parseUintParam(id)
+		if err != nil {
+			utils.BadRequest(c, "Invalid studio ID")
+			return nil, false
+		}
+		studioID = parsed
+	} else if studioID == 0 {
+		if h := c.GetHeader(StudioHeader); h != "" {
+			parsed, err := parseUintParam(h)
+			if err != nil {
+				utils.BadRequest(c, "Invalid "+StudioHeader)
+				return nil, false
+			}
+			studioID = parsed
+		}
+	}
+
+	if studioID == 0 {
+		studio, err := currentStudio(db, userID, perm)
+		if err != nil {
+			utils.NotFound(c, "未找到你的工作室，请先创建")
+			return nil, false
+		}
+		return studio, true
+	}
+
+	var studio models.Studio
+	if err := db.First(&studio, studioID).Error; err != nil {
+		utils.NotFound(c, "Studio not found")
+		return nil, false
+	}
+	if !hasStudioPermission(db, &studio, userID, perm) {
+		utils.Forbidden(c, "无权执行该工作室操作")
+		return nil, false
+	}
+	return &studio, true
+}
+
+// StudioAccess 当前用户可进入的工作室及其身份
+type StudioAccess struct {
+	Studio      models.Studio             `json:"studio"`
+	Role        string                    `json:"role"` // owner 或员工角色
+	Permissions []models.StudioPermission `json:"permissions"`
+}
+
+// accessibleStudios 当前用户拥有或任职的全部工作室（拥有的在前）
+func accessibleStudios(db *gorm.DB, userID uint) ([]StudioAccess, error) {
+	var owned []models.Studio
+	if err := db.Where("owner_id = ?", userID).Order("id ASC").Find(&owned).Error; err != nil {
+		return nil, err
+	}
+	var staff []models.StudioStaff
+	if err := db.Where("user_id = ?", userID).Preload("Studio").Order("id ASC").Find(&staff).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]StudioAccess, 0, len(owned)+len(staff))
+	for _, st := range owned {
+		out = append(out, StudioAccess{Studio: st, Role: studioOwnerAccess, Permissions: models.AllStudioPermissions})
+	}
+	for _, sf := range staff {
+		if sf.Studio.ID == 0 {
+			continue
+		}
+		out = append(out, StudioAccess{Studio: sf.Studio, Role: string(sf.Role), Permissions: models.StaffRolePermissions[sf.Role]})
+	}
+	return out, nil
+}
+
+// Mine 当前用户拥有或任职的工作室列表（用于选择 X-Studio-ID）
+func (stc *StudioStaffController) Mine(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	studios, err := accessibleStudios(config.GetDB(), userID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to get studios")
+		return
+	}
+
+	utils.Success(c, studios)
+}
+
+// List 工作室员工列表（任何员工可查看）
+func (stc *StudioStaffController) List(c *gin.Context) {
+	_, studio, ok := authorizeStudio(c, models.PermStudioView)
+	if !ok {
+		return
+	}
+
+	var staff []models.StudioStaff
+	if err := config.GetDB().Where("studio_id = ?", studio.ID).Preload("User").
+		Order("created_at ASC, id ASC").Find(&staff).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get staff")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"owner_id":    studio.OwnerID,
+		"staff":       staff,
+		"permissions": models.StaffRolePermissions,
+	})
+}
+
+// Add 所有者按用户名添加员工（须为工作室角色账号）
+func (stc *StudioStaffController) Add(c *gin.Context) {
+	userID, studio, ok := authorizeStudio(c, models.PermStudioStaff)
+	if !ok {
+		return
+	}
+
+	var req AddStaffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var target models.User
+	if err := db.Where("username = ?", strings.TrimSpace(req.Username)).First(&target).Error; err != nil {
+		utils.NotFound(c, "用户不存在")
+		return
+	}
+	if target.Role != models.RoleStudio {
+		utils.BadRequest(c, "员工须为工作室角色账号")
+		return
+	}
+	if target.ID == studio.OwnerID {
+		utils.BadRequest(c, "所有者无需添加为员工")
+		return
+	}
+	var count int64
+	db.Model(&models.StudioStaff{}).Where("studio_id = ? AND user_id = ?", studio.ID, target.ID).Count(&count)
+	if count > 0 {
+		utils.BadRequest(c, "该用户已是员工")
+		return
+	}
+
+	staff := models.StudioStaff{StudioID: studio.ID, UserID: target.ID, Role: req.Role, CreatedBy: userID}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&staff).Error; err != nil {
+			return err
+		}
+		return notify(tx, target.ID, models.NotificationStudioRelation,
+			"你已成为工作室员工", "你已被添加为「"+studio.Name+"」的员工", studio.ID)
+	}); err != nil {
+		utils.InternalServerError(c, "Failed to add staff")
+		return
+	}
+	invalidateStudioAccess(studio.ID, target.ID)
+
+	staff.User = target
+	utils.SuccessWithMessage(c, "员工已添加", staff)
+}
+
+// UpdateRole 所有者调整员工角色
+func (stc *StudioStaffController) UpdateRole(c *gin.Context) {
+	_, studio, ok := authorizeStudio(c, models.PermStudioStaff)
+	if !ok {
+		return
+	}
+	staffUserID, err := parseUintParam(c.Param("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	var req UpdateStaffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var staff models.StudioStaff
+	if err := db.Where("studio_id = ? AND user_id = ?", studio.ID, staffUserID).First(&staff).Error; err != nil {
+		utils.NotFound(c, "员工不存在")
+		return
+	}
+	if err := db.Model(&staff).Update("role", req.Role).Error; err != nil {
+		utils.InternalServerError(c, "Failed to update staff")
+		return
+	}
+	invalidateStudioAccess(studio.ID, staffUserID)
+
+	utils.SuccessWithMessage(c, "员工角色已更新", staff)
+}
+
+// Remove 所有者移除员工
+func (stc *StudioStaffController) Remove(c *gin.Context) {
+	_, studio, ok := authorizeStudio(c, models.PermStudioStaff)
+	if !ok {
+		return
+	}
+	staffUserID, err := parseUintParam(c.Param("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	res := config.GetDB().Where("studio_id = ? AND user_id = ?", studio.ID, staffUserID).Delete(&models.StudioStaff{})
+	if res.Error != nil {
+		utils.InternalServerError(c, "Failed to remove staff")
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.NotFound(c, "员工不存在")
+		return
+	}
+	invalidateStudioAccess(studio.ID, staffUserID)
+
+	utils.SuccessWithMessage(c, "员工已移除", nil)
+}
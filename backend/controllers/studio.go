@@ -1,7 +1,11 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"strconv"
+	"strings"
 	"time"
 
 	"companion-platform-backend/config"
@@ -36,6 +40,78 @@ type ProcessApplicationRequest struct {
 	Notes  string                `json:"notes"`
 }
 
+// RelationChangeRequest 移除成员 / 退出工作室（请求体可省略）
+type RelationChangeRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+var errRelationState = errors.New("关联状态已变化，请刷新后重试")
+
+// logRelationStatusTx 追加一条关联状态流转历史
+func logRelationStatusTx(tx *gorm.DB, relationID, actorID uint, from, to models.RelationStatus, note string) error {
+	return tx.Create(&models.RelationStatusLog{
+		RelationID: relationID,
+		ActorID:    actorID,
+		FromStatus: from,
+		ToStatus:   to,
+		Note:       note,
+	}).Error
+}
+
+// relationHistoryOrder 预加载流转历史时按时间正序
+func relationHistoryOrder(tx *gorm.DB) *gorm.DB {
+	return tx.Order("created_at ASC, id ASC")
+}
+
+// transitionRelationTx 条件更新关联状态（当前状态须在 from 之中且未被并发修改），同时记录流转时间、操作人与原因，
+// 并追加流转历史；extra 为需要一并更新的字段。成功后 relation 重新加载为最新状态
+func transitionRelationTx(tx *gorm.DB, relation *models.ProviderStudioRelation, from []models.RelationStatus,
+	to models.RelationStatus, actorID uint, reason string, extra map[string]interface{}) error {
+
+	allowed := false
+	for _, st := range from {
+		if st == relation.Status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return errRelationState
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":            to,
+		"status_changed_at": &now,
+		"status_changed_by": actorID,
+		"status_reason":     reason,
+	}
+	for k, v := range extra {
+		updates[k] = v
+	}
+	old := relation.Status
+	res := tx.Model(&models.ProviderStudioRelation{}).
+		Where("id = ? AND status = ?", relation.ID, old).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errRelationState
+	}
+	if err := logRelationStatusTx(tx, relation.ID, actorID, old, to, reason); err != nil {
+		return err
+	}
+	return tx.First(relation, relation.ID).Error
+}
+
+// withReason 通知内容附上原因（原因为空时原样返回）
+func withReason(content, reason string) string {
+	if reason == "" {
+		return content
+	}
+	return content + "，原因：" + reason
+}
+
 // CreateStudio 创建工作室
 func (sc *StudioController) CreateStudio(c *gin.Context) {
 	userID, err := middleware.GetCurrentUserID(c)
@@ -106,8 +182,13 @@ func (sc *StudioController) GetStudioList(c *gin.Context) {
 	var total int64
 	query.Count(&total)
 
+	// sort=ranking 按排名分（贝叶斯平滑 + 时间衰减）降序
+	if c.Query("sort") == "ranking" {
+		query = rankingOrder(query, "studios", models.ReviewTargetStudio)
+	}
+
 	var studios []models.Studio
-	if err := query.Preload("Owner").Offset(offset).Limit(pageSize).Find(&studios).Error; err != nil {
+	if err := query.Preload("Owner").Preload("RatingScore").Offset(offset).Limit(pageSize).Find(&studios).Error; err != nil {
 		utils.InternalServerError(c, "Failed to get studio list")
 		return
 	}
@@ -166,9 +247,9 @@ func (sc *StudioController) UpdateStudio(c *gin.Context) {
 		return
 	}
 
-	// 检查权限：只有工作室所有者可以更新
-	if studio.OwnerID != userID {
-		utils.Forbidden(c, "Only studio owner can update studio information")
+	// 检查权限：所有者或有编辑权限的员工
+	if !hasStudioPermission(db, &studio, userID, models.PermStudioEdit) {
+		utils.Forbidden(c, "No permission to update studio information")
 		return
 	}
 
@@ -188,7 +269,14 @@ func (sc *StudioController) UpdateStudio(c *gin.Context) {
 	utils.SuccessWithMessage(c, "Studio updated successfully", studio)
 }
 
-// ApplyToJoinStudio 申请加入工作室
+// ApplyToStudioRequest 申请加入工作室请求
+type ApplyToStudioRequest struct {
+	Notes       string            `json:"notes"`
+	Fields      map[string]string `json:"fields"`       // 工作室申请策略要求的资料项
+	AcceptTerms bool              `json:"accept_terms"` // 同意工作室的服务者条款
+}
+
+// ApplyToJoinStudio 申请加入工作室，按工作室申请策略校验并执行自动批准/拒绝规则
 func (sc *StudioController) ApplyToJoinStudio(c *gin.Context) {
 	id := c.Param("id")
 	studioID, err := strconv.ParseUint(id, 10, 32)
@@ -203,9 +291,7 @@ func (sc *StudioController) ApplyToJoinStudio(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		Notes string `json:"notes"`
-	}
+	var req ApplyToStudioRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, err.Error())
 		return
@@ -213,35 +299,402 @@ func (sc *StudioController) ApplyToJoinStudio(c *gin.Context) {
 
 	db := config.GetDB()
 
-	// 检查工作室是否存在
+	// 检查工作室是否存在且未停用
 	var studio models.Studio
 	if err := db.First(&studio, uint(studioID)).Error; err != nil {
 		utils.NotFound(c, "Studio not found")
 		return
 	}
+	if !studio.IsActive {
+		utils.BadRequest(c, "工作室已停用，暂不接受申请")
+		return
+	}
 
-	// 检查是否已经有关联关系
+	// 按工作室申请策略校验条款与必填资料
+	policy := studioApplicationPolicy(db, studio.ID)
+	if policy != nil && policy.Terms != "" && !req.AcceptTerms {
+		utils.BadRequest(c, "请先阅读并同意工作室的服务者条款")
+		return
+	}
+	if missing := missingApplicationFields(policy, req.Fields); len(missing) > 0 {
+		utils.BadRequest(c, "缺少必填申请资料："+strings.Join(missing, "、"))
+		return
+	}
+
+	// 检查是否已经有关联关系：被拒绝、被移除或已退出的可在原记录上重新申请
 	var existingRelation models.ProviderStudioRelation
 	if err := db.Where("provider_id = ? AND studio_id = ?", userID, studioID).First(&existingRelation).Error; err == nil {
-		utils.BadRequest(c, "Application already exists")
+		switch existingRelation.Status {
+		case models.StatusPending:
+			utils.BadRequest(c, "Application already exists")
+			return
+		case models.StatusApproved:
+			utils.BadRequest(c, "Already a member of this studio")
+			return
+		}
+	}
+
+	now := time.Now()
+	var termsAcceptedAt *time.Time
+	if policy != nil && policy.Terms != "" {
+		termsAcceptedAt = &now
+	}
+	rule, detail := evaluateApplication(db, policy, userID)
+	// map 更新不经过 serializer，需手动序列化
+	fieldsJSON, _ := json.Marshal(req.Fields)
+
+	relation := existingRelation
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if relation.ID == 0 {
+			// 创建申请
+			relation = models.ProviderStudioRelation{
+				ProviderID:        userID,
+				StudioID:          uint(studioID),
+				Status:            models.StatusPending,
+				AppliedAt:         now,
+				Notes:             req.Notes,
+				ApplicationFields: req.Fields,
+				TermsAcceptedAt:   termsAcceptedAt,
+				RuleFired:         rule,
+				RuleDetail:        detail,
+			}
+			if err := tx.Create(&relation).Error; err != nil {
+				return err
+			}
+			if err := logRelationStatusTx(tx, relation.ID, userID, "", models.StatusPending, req.Notes); err != nil {
+				return err
+			}
+		} else {
+			// 被拒绝、被移除或已退出的服务者在原关联记录上重新提交申请
+			if err := transitionRelationTx(tx, &relation,
+				[]models.RelationStatus{models.StatusRejected, models.StatusRemoved, models.StatusLeft},
+				models.StatusPending, userID, req.Notes, map[string]interface{}{
+					"applied_at":         now,
+					"processed_at":       nil,
+					"notes":              req.Notes,
+					"application_fields": string(fieldsJSON),
+					"terms_accepted_at":  termsAcceptedAt,
+					"rule_fired":         rule,
+					"rule_detail":        detail,
+				}); err != nil {
+				return err
+			}
+			if err := notify(tx, studio.OwnerID, models.NotificationStudioRelation,
+				"服务者重新申请加入", "有服务者重新申请加入「"+studio.Name+"」", relation.ID); err != nil {
+				return err
+			}
+		}
+		return applyApplicationRuleTx(tx, &relation, &studio, rule, detail)
+	})
+	if err != nil {
+		if errors.Is(err, errRelationState) {
+			utils.BadRequest(c, err.Error())
+		} else {
+			utils.InternalServerError(c, "Failed to create application")
+		}
+		return
+	}
+
+	message := "Application submitted successfully"
+	switch rule {
+	case models.RuleAutoApprove:
+		message = "申请已按工作室规则自动批准"
+	case models.RuleAutoReject:
+		message = "申请未满足工作室要求，已自动拒绝"
+	}
+	utils.SuccessWithMessage(c, message, relation)
+}
+
+// applyApplicationRuleTx 执行申请时触发的自动规则：以系统身份（actor 0）批准或拒绝并通知服务者，人工审核则保持待处理
+func applyApplicationRuleTx(tx *gorm.DB, relation *models.ProviderStudioRelation, studio *models.Studio, rule models.ApplicationRule, detail string) error {
+	var to models.RelationStatus
+	var title string
+	switch rule {
+	case models.RuleAutoApprove:
+		to, title = models.StatusApproved, "加入申请已自动通过"
+	case models.RuleAutoReject:
+		to, title = models.StatusRejected, "加入申请未通过"
+	default:
+		return nil
+	}
+
+	if err := transitionRelationTx(tx, relation, []models.RelationStatus{models.StatusPending}, to, 0,
+		"[自动] "+detail, map[string]interface{}{"processed_at": time.Now()}); err != nil {
+		return err
+	}
+	return notify(tx, relation.ProviderID, models.NotificationStudioRelation,
+		title, withReason("你对「"+studio.Name+"」的加入申请已按工作室规则自动处理", detail), relation.ID)
+}
+
+// RemoveMember 工作室所有者移除成员（approved → removed），被移除的服务者之后可重新申请
+func (sc *StudioController) RemoveMember(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	studioID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid studio ID")
+		return
+	}
+	providerID, err := parseUintParam(c.Param("provider_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid provider ID")
+		return
+	}
+
+	var req RelationChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var studio models.Studio
+	if err := db.First(&studio, studioID).Error; err != nil {
+		utils.NotFound(c, "Studio not found")
+		return
+	}
+	if !hasStudioPermission(db, &studio, userID, models.PermStudioMembers) {
+		utils.Forbidden(c, "No permission to remove members")
+		return
+	}
+
+	var relation models.ProviderStudioRelation
+	if err := db.Where("provider_id = ? AND studio_id = ? AND status = ?", providerID, studio.ID, models.StatusApproved).
+		First(&relation).Error; err != nil {
+		utils.NotFound(c, "该服务者不是工作室成员")
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := transitionRelationTx(tx, &relation, []models.RelationStatus{models.StatusApproved},
+			models.StatusRemoved, userID, req.Reason, nil); err != nil {
+			return err
+		}
+		return notify(tx, relation.ProviderID, models.NotificationStudioRelation,
+			"已被移出工作室", withReason("你已被移出「"+studio.Name+"」", req.Reason), relation.ID)
+	})
+	if err != nil {
+		if errors.Is(err, errRelationState) {
+			utils.BadRequest(c, err.Error())
+		} else {
+			utils.InternalServerError(c, "Failed to remove member")
+		}
+		return
+	}
+
+	utils.SuccessWithMessage(c, "成员已移除", relation)
+}
+
+// LeaveStudio 服务者退出工作室或撤回待审核的申请（approved / pending → left），之后可重新申请
+func (sc *StudioController) LeaveStudio(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	studioID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid studio ID")
+		return
+	}
+
+	var req RelationChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var relation models.ProviderStudioRelation
+	if err := db.Preload("Studio").Where("provider_id = ? AND studio_id = ?", userID, studioID).
+		First(&relation).Error; err != nil {
+		utils.NotFound(c, "你未加入或申请该工作室")
+		return
+	}
+	if relation.Status != models.StatusApproved && relation.Status != models.StatusPending {
+		utils.BadRequest(c, "你未加入或申请该工作室")
+		return
+	}
+	studio := relation.Studio
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := transitionRelationTx(tx, &relation,
+			[]models.RelationStatus{models.StatusApproved, models.StatusPending},
+			models.StatusLeft, userID, req.Reason, nil); err != nil {
+			return err
+		}
+		return notify(tx, studio.OwnerID, models.NotificationStudioRelation,
+			"服务者已退出", withReason("有服务者退出了「"+studio.Name+"」", req.Reason), relation.ID)
+	})
+	if err != nil {
+		if errors.Is(err, errRelationState) {
+			utils.BadRequest(c, err.Error())
+		} else {
+			utils.InternalServerError(c, "Failed to leave studio")
+		}
+		return
+	}
+
+	utils.SuccessWithMessage(c, "已退出工作室", relation)
+}
+
+// DeactivateStudioRequest 停用工作室
+type DeactivateStudioRequest struct {
+	Reason        string                     `json:"reason" binding:"max=500"`
+	BalancePolicy models.StudioBalancePolicy `json:"balance_policy" binding:"required,oneof=block transfer refund"`
+}
+
+var errStudioBusy = errors.New("工作室仍有已预约、进行中或待确认 / 申诉中的陪玩、生效中的订阅或冻结余额，请先处理后再停用")
+
+// settleStudioBalancesTx 按停用策略处理工作室名下的玩家余额，返回处理的余额条数。
+// block 不变动；transfer 转入对应服务者的个人余额；refund 清零并记退还流水
+func settleStudioBalancesTx(tx *gorm.DB, studio *models.Studio, policy models.StudioBalancePolicy, operatorID uint) (int, error) {
+	if policy == models.BalancePolicyBlock {
+		return 0, nil
+	}
+
+	var balances []models.Balance
+	if err := tx.Where("studio_id = ? AND amount > 0", studio.ID).Order("id ASC").Find(&balances).Error; err != nil {
+		return 0, err
+	}
+	outType, note := models.TransactionTypeClosureRefund, "余额已退还"
+	if policy == models.BalancePolicyTransfer {
+		outType, note = models.TransactionTypeTransferOut, "余额已转入服务者个人余额"
+	}
+	desc := "工作室「" + studio.Name + "」停用 · " + note
+	for _, b := range balances {
+		if _, err := adjustBalanceTx(tx, b.PlayerID, b.ProviderID, studio.ID, b.Type,
+			b.Amount.Neg(), outType, operatorID, desc); err != nil {
+			return 0, err
+		}
+		if policy == models.BalancePolicyTransfer {
+			if _, err := adjustBalanceTx(tx, b.PlayerID, b.ProviderID, 0, b.Type,
+				b.Amount, models.TransactionTypeTransferIn, operatorID, desc); err != nil {
+				return 0, err
+			}
+		}
+		if err := notify(tx, b.PlayerID, models.NotificationStudioStatus, "工作室已停用", desc, b.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(balances), nil
+}
+
+// Deactivate 所有者停用工作室：不再接受申请与余额变动，历史数据仍可查看；名下玩家余额按 balance_policy 处理
+func (sc *StudioController) Deactivate(c *gin.Context) {
+	userID, studio, ok := authorizeStudio(c, models.PermStudioStaff)
+	if !ok {
+		return
+	}
+
+	var req DeactivateStudioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if !studio.IsActive {
+		utils.BadRequest(c, "工作室已停用")
+		return
+	}
+
+	db := config.GetDB()
+	var settled int
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		var busy int64
+		tx.Model(&models.PlayRecord{}).Where("studio_id = ? AND status IN ?", studio.ID,
+			[]models.PlayStatus{models.PlayStatusBooked, models.PlayStatusActive}).Count(&busy)
+		if busy == 0 {
+			tx.Model(&models.Subscription{}).
+				Where("studio_id = ? AND status = ?", studio.ID, models.SubscriptionStatusActive).Count(&busy)
+		}
+		if busy == 0 {
+			// 确认窗口内或申诉中的已完成记录：停用后余额无法冻结 / 退回，须先了结
+			tx.Model(&models.PlayRecord{}).Where(
+				"studio_id = ? AND status = ? AND (confirm_status = ? OR (confirm_status = ? AND (confirm_deadline IS NULL OR confirm_deadline > ?)))",
+				studio.ID, models.PlayStatusCompleted, models.ConfirmStatusDisputed, models.ConfirmStatusPending, time.Now()).Count(&busy)
+		}
+		if busy == 0 {
+			tx.Model(&models.Balance{}).Where("studio_id = ? AND frozen_amount > 0", studio.ID).Count(&busy)
+		}
+		if busy > 0 {
+			return errStudioBusy
+		}
+
+		n, err := settleStudioBalancesTx(tx, studio, req.BalancePolicy, userID)
+		if err != nil {
+			return err
+		}
+		settled = n
+
+		now := time.Now()
+		res := tx.Model(&models.Studio{}).Where("id = ? AND is_active = ?", studio.ID, true).Updates(map[string]interface{}{
+			"is_active":           false,
+			"deactivated_at":      &now,
+			"deactivation_reason": req.Reason,
+			"balance_policy":      req.BalancePolicy,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errStudioInactive
+		}
+
+		var members []models.ProviderStudioRelation
+		tx.Where("studio_id = ? AND status = ?", studio.ID, models.StatusApproved).Find(&members)
+		for _, m := range members {
+			if err := notify(tx, m.ProviderID, models.NotificationStudioStatus, "工作室已停用",
+				withReason("「"+studio.Name+"」已停用", req.Reason), studio.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if txErr != nil {
+		switch {
+		case errors.Is(txErr, errStudioBusy), errors.Is(txErr, errStudioInactive):
+			utils.BadRequest(c, txErr.Error())
+		default:
+			utils.InternalServerError(c, "Failed to deactivate studio")
+		}
 		return
 	}
 
-	// 创建申请
-	relation := models.ProviderStudioRelation{
-		ProviderID: userID,
-		StudioID:   uint(studioID),
-		Status:     models.StatusPending,
-		AppliedAt:  time.Now(),
-		Notes:      req.Notes,
+	db.First(studio, studio.ID)
+	utils.SuccessWithMessage(c, "工作室已停用", gin.H{
+		"studio":           studio,
+		"settled_balances": settled,
+	})
+}
+
+// Reactivate 所有者重新启用工作室（冻结的余额恢复可用）
+func (sc *StudioController) Reactivate(c *gin.Context) {
+	_, studio, ok := authorizeStudio(c, models.PermStudioStaff)
+	if !ok {
+		return
+	}
+	if studio.IsActive {
+		utils.BadRequest(c, "工作室未停用")
+		return
 	}
 
-	if err := db.Create(&relation).Error; err != nil {
-		utils.InternalServerError(c, "Failed to create application")
+	db := config.GetDB()
+	if err := db.Model(studio).Updates(map[string]interface{}{
+		"is_active":           true,
+		"deactivated_at":      nil,
+		"deactivation_reason": "",
+	}).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reactivate studio")
 		return
 	}
 
-	utils.SuccessWithMessage(c, "Application submitted successfully", relation)
+	db.First(studio, studio.ID)
+	utils.SuccessWithMessage(c, "工作室已重新启用", studio)
 }
 
 // GetStudioApplications 获取工作室的申请列表
@@ -261,15 +714,15 @@ func (sc *StudioController) GetStudioApplications(c *gin.Context) {
 
 	db := config.GetDB()
 
-	// 检查权限：只有工作室所有者可以查看申请
+	// 检查权限：所有者或有申请审批权限的员工
 	var studio models.Studio
 	if err := db.First(&studio, uint(studioID)).Error; err != nil {
 		utils.NotFound(c, "Studio not found")
 		return
 	}
 
-	if studio.OwnerID != userID {
-		utils.Forbidden(c, "Only studio owner can view applications")
+	if !hasStudioPermission(db, &studio, userID, models.PermStudioApplications) {
+		utils.Forbidden(c, "No permission to view applications")
 		return
 	}
 
@@ -296,7 +749,8 @@ func (sc *StudioController) GetStudioApplications(c *gin.Context) {
 	query.Count(&total)
 
 	var relations []models.ProviderStudioRelation
-	if err := query.Preload("Provider").Offset(offset).Limit(pageSize).Find(&relations).Error; err != nil {
+	if err := query.Preload("Provider").Preload("History", relationHistoryOrder).Preload("History.Actor").
+		Offset(offset).Limit(pageSize).Find(&relations).Error; err != nil {
 		utils.InternalServerError(c, "Failed to get applications")
 		return
 	}
@@ -334,9 +788,9 @@ func (sc *StudioController) ProcessApplication(c *gin.Context) {
 		return
 	}
 
-	// 检查权限：只有工作室所有者可以处理申请
-	if relation.Studio.OwnerID != userID {
-		utils.Forbidden(c, "Only studio owner can process applications")
+	// 检查权限：所有者或有申请审批权限的员工
+	if !hasStudioPermission(db, &relation.Studio, userID, models.PermStudioApplications) {
+		utils.Forbidden(c, "No permission to process applications")
 		return
 	}
 
@@ -345,17 +799,23 @@ func (sc *StudioController) ProcessApplication(c *gin.Context) {
 		utils.BadRequest(c, "Application has already been processed")
 		return
 	}
-
-	// 更新申请状态
-	now := time.Now()
-	updates := map[string]interface{}{
-		"status":       req.Status,
-		"processed_at": &now,
-		"notes":        req.Notes,
+	// 停用期间只能拒绝，不能批准新成员
+	if req.Status == models.StatusApproved && !relation.Studio.IsActive {
+		utils.BadRequest(c, "工作室已停用，不能批准申请")
+		return
 	}
 
-	if err := db.Model(&relation).Updates(updates).Error; err != nil {
-		utils.InternalServerError(c, "Failed to process application")
+	// 更新申请状态（条件更新，防止并发重复处理）
+	now := time.Now()
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return transitionRelationTx(tx, &relation, []models.RelationStatus{models.StatusPending}, req.Status, userID, req.Notes,
+			map[string]interface{}{"processed_at": &now, "notes": req.Notes})
+	}); err != nil {
+		if errors.Is(err, errRelationState) {
+			utils.BadRequest(c, "Application has already been processed")
+		} else {
+			utils.InternalServerError(c, "Failed to process application")
+		}
 		return
 	}
 
@@ -370,9 +830,10 @@ type StudioMember struct {
 	MoneyFlow   decimal.Decimal       `json:"money_flow"`
 	Rating      float64               `json:"rating"`
 	JoinedAt    *time.Time            `json:"joined_at"`
+	MatchStats  MatchStats            `json:"match_stats"`
 }
 
-// GetStudioMembers 获取工作室成员列表（工作室所有者查看自己的工作室）
+// GetStudioMembers 获取工作室成员列表（工作室所有者或员工查看；/studio/:id/members 或 X-Studio-ID 选择工作室）
 func (sc *StudioController) GetStudioMembers(c *gin.Context) {
 	userID, err := middleware.GetCurrentUserID(c)
 	if err != nil {
@@ -381,9 +842,8 @@ func (sc *StudioController) GetStudioMembers(c *gin.Context) {
 	}
 
 	db := config.GetDB()
-	var studio models.Studio
-	if err := db.Where("owner_id = ?", userID).First(&studio).Error; err != nil {
-		utils.NotFound(c, "未找到你的工作室，请先创建")
+	studio, ok := selectStudio(c, db, userID, models.PermStudioView, 0)
+	if !ok {
 		return
 	}
 
@@ -410,11 +870,12 @@ func (sc *StudioController) GetStudioMembers(c *gin.Context) {
 			Select("COALESCE(SUM(amount),0)").Scan(&m.MoneyFlow)
 		var avg *float64
 		db.Model(&models.Review{}).
-			Where("target_type = ? AND target_id = ?", models.ReviewTargetProvider, rel.ProviderID).
+			Where("target_type = ? AND target_id = ? AND status = ?", models.ReviewTargetProvider, rel.ProviderID, models.ReviewStatusVisible).
 			Select("AVG(rating)").Scan(&avg)
 		if avg != nil {
 			m.Rating = *avg
 		}
+		m.MatchStats = providerMatchStats(db, rel.ProviderID, 0)
 		members = append(members, m)
 	}
 
@@ -432,7 +893,8 @@ func (sc *StudioController) GetMyRelations(c *gin.Context) {
 	db := config.GetDB()
 	var relations []models.ProviderStudioRelation
 	if err := db.Where("provider_id = ?", userID).
-		Preload("Studio").Preload("Studio.Owner").Order("applied_at DESC").Find(&relations).Error; err != nil {
+		Preload("Studio").Preload("Studio.Owner").Preload("History", relationHistoryOrder).Preload("History.Actor").
+		Order("applied_at DESC").Find(&relations).Error; err != nil {
 		utils.InternalServerError(c, "Failed to get relations")
 		return
 	}
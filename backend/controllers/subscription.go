@@ -0,0 +1,461 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/middleware"
+	"companion-platform-backend/models"
+	"companion-platform-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type SubscriptionController struct{}
+
+// CreateSubscriptionRequest 玩家创建周期订阅
+type CreateSubscriptionRequest struct {
+	ProviderID      uint               `json:"provider_id" binding:"required"`
+	StudioID        uint               `json:"studio_id"`
+	GameID          uint               `json:"game_id"`
+	GameModeID      uint               `json:"game_mode_id"`
+	GameName        string             `json:"game_name"`
+	GameMode        string             `json:"game_mode"`
+	SettleType      models.BalanceType `json:"settle_type" binding:"omitempty,oneof=money time point"`
+	Weekdays        []int              `json:"weekdays" binding:"required,min=1,dive,min=0,max=6"`
+	TimeOfDay       string             `json:"time_of_day" binding:"required"` // HH:MM
+	PricePerSession decimal.Decimal    `json:"price_per_session"`
+	TotalSessions   int                `json:"total_sessions" binding:"required,min=1,max=200"`
+	StartDate       string             `json:"start_date"` // 2006-01-02，默认今天
+	Notes           string             `json:"notes"`
+}
+
+var errSubscriptionState = errors.New("订阅当前状态不允许该操作")
+
+// parseTimeOfDay 解析 HH:MM
+func parseTimeOfDay(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// parseWeekdays 解析订阅上保存的星期列表
+func parseWeekdays(s string) []int {
+	var days []int
+	for _, part := range strings.Split(s, ",") {
+		if d, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// subscriptionSlots 从 from 所在日期起，按星期与时刻生成严格晚于 after 的前 n 个排期时间
+func subscriptionSlots(from, after time.Time, weekdays []int, hour, minute, n int) []time.Time {
+	want := map[time.Weekday]bool{}
+	for _, d := range weekdays {
+		want[time.Weekday(d)] = true
+	}
+	slots := make([]time.Time, 0, n)
+	if len(want) == 0 {
+		return slots
+	}
+	day := time.Date(from.Year(), from.Month(), from.Day(), hour, minute, 0, 0, from.Location())
+	for len(slots) < n {
+		if want[day.Weekday()] && day.After(after) {
+			slots = append(slots, day)
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return slots
+}
+
+// bookSessionsTx 为订阅从 from 起排期 n 场 booked 记录
+func bookSessionsTx(tx *gorm.DB, sub *models.Subscription, from time.Time, n int) error {
+	hour, minute, err := parseTimeOfDay(sub.TimeOfDay)
+	if err != nil {
+		return err
+	}
+	for _, slot := range subscriptionSlots(from, time.Now(), parseWeekdays(sub.Weekdays), hour, minute, n) {
+		scheduled := slot
+		record := models.PlayRecord{
+			PlayerID:       sub.PlayerID,
+			ProviderID:     sub.ProviderID,
+			StudioID:       sub.StudioID,
+			SubscriptionID: &sub.ID,
+			ScheduledAt:    &scheduled,
+			GameID:         sub.GameID,
+			GameModeID:     sub.GameModeID,
+			GameName:       sub.GameName,
+			GameMode:       sub.GameMode,
+			StartTime:      scheduled,
+			Amount:         sub.PricePerSession,
+			SettleType:     sub.SettleType,
+			Status:         models.PlayStatusBooked,
+			Description:    fmt.Sprintf("订阅 #%d", sub.ID),
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseUnusedTx 取消订阅下尚未开始的预约，并按未使用的已预付场次比例退款
+func releaseUnusedTx(tx *gorm.DB, sub *models.Subscription, operatorID uint, reason string) (decimal.Decimal, error) {
+	now := time.Now()
+	var booked []models.PlayRecord
+	if err := tx.Where("subscription_id = ? AND status = ?", sub.ID, models.PlayStatusBooked).
+		Find(&booked).Error; err != nil {
+		return decimal.Zero, err
+	}
+	for i := range booked {
+		if err := tx.Model(&booked[i]).Updates(map[string]interface{}{
+			"status":      models.PlayStatusCancelled,
+			"end_time":    &now,
+			"description": appendSystemNote(booked[i].Description, "订阅"+reason+"，预约已取消"),
+		}).Error; err != nil {
+			return decimal.Zero, err
+		}
+	}
+
+	// 进行中的一场仍会按预付结算，不退
+	var inProgress int64
+	tx.Model(&models.PlayRecord{}).
+		Where("subscription_id = ? AND status = ?", sub.ID, models.PlayStatusActive).Count(&inProgress)
+
+	unused := sub.PaidSessions - sub.UsedSessions - int(inProgress)
+	if unused <= 0 {
+		return decimal.Zero, nil
+	}
+	refund := sub.PricePerSession.Mul(decimal.NewFromInt(int64(unused)))
+	if _, err := adjustBalanceTx(tx, sub.PlayerID, sub.ProviderID, sub.StudioID, sub.SettleType,
+		refund, models.TransactionTypeRefund, operatorID,
+		fmt.Sprintf("订阅 #%d %s退款 · %d 场", sub.ID, reason, unused)); err != nil {
+		return decimal.Zero, err
+	}
+	sub.PaidSessions -= unused
+	sub.RefundedAmount = sub.RefundedAmount.Add(refund)
+	return refund, nil
+}
+
+// Create 玩家创建订阅：从余额预付全部场次，并按排期生成预约记录
+func (sc *SubscriptionController) Create(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	var req CreateSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if req.PricePerSession.LessThanOrEqual(decimal.Zero) {
+		utils.BadRequest(c, "单价必须大于 0")
+		return
+	}
+	if _, _, err := parseTimeOfDay(req.TimeOfDay); err != nil {
+		utils.BadRequest(c, "time_of_day 格式应为 HH:MM")
+		return
+	}
+	startDate := time.Now()
+	if req.StartDate != "" {
+		d, err := time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+		if err != nil {
+			utils.BadRequest(c, "start_date 格式应为 2006-01-02")
+			return
+		}
+		startDate = d
+	}
+	settleType := req.SettleType
+	if settleType == "" {
+		settleType = models.BalanceTypeMoney
+	}
+
+	db := config.GetDB()
+	var provider models.User
+	if err := db.Where("id = ? AND role = ?", req.ProviderID, models.RoleProvider).First(&provider).Error; err != nil {
+		utils.BadRequest(c, "服务者不存在")
+		return
+	}
+	if req.StudioID != 0 {
+		var relation models.ProviderStudioRelation
+		if err := db.Where("provider_id = ? AND studio_id = ? AND status = ?",
+			provider.ID, req.StudioID, models.StatusApproved).First(&relation).Error; err != nil {
+			utils.BadRequest(c, "该服务者未加入此工作室")
+			return
+		}
+	}
+	game, mode, err := resolveGame(db, req.GameID, req.GameModeID, req.GameName, req.GameMode)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	days := make([]string, 0, len(req.Weekdays))
+	sort.Ints(req.Weekdays)
+	for _, d := range req.Weekdays {
+		days = append(days, strconv.Itoa(d))
+	}
+
+	sub := models.Subscription{
+		PlayerID:        userID,
+		ProviderID:      provider.ID,
+		StudioID:        req.StudioID,
+		GameID:          game.ID,
+		GameName:        game.Name,
+		SettleType:      settleType,
+		Weekdays:        strings.Join(days, ","),
+		TimeOfDay:       req.TimeOfDay,
+		PricePerSession: req.PricePerSession,
+		TotalSessions:   req.TotalSessions,
+		TotalPrice:      req.PricePerSession.Mul(decimal.NewFromInt(int64(req.TotalSessions))),
+		PaidSessions:    req.TotalSessions,
+		RefundedAmount:  decimal.Zero,
+		Status:          models.SubscriptionStatusActive,
+		Notes:           req.Notes,
+		StartDate:       startDate,
+	}
+	if mode != nil {
+		sub.GameModeID, sub.GameMode = mode.ID, mode.Name
+	}
+
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&sub).Error; err != nil {
+			return err
+		}
+		if _, err := adjustBalanceTx(tx, sub.PlayerID, sub.ProviderID, sub.StudioID, sub.SettleType,
+			sub.TotalPrice.Neg(), models.TransactionTypeConsume, userID,
+			fmt.Sprintf("订阅 #%d 预付 · %d 场", sub.ID, sub.TotalSessions)); err != nil {
+			return err
+		}
+		return bookSessionsTx(tx, &sub, sub.StartDate, sub.TotalSessions)
+	})
+	if txErr != nil {
+		if errors.Is(txErr, errStudioInactive) {
+			utils.BadRequest(c, txErr.Error())
+			return
+		}
+		if errors.Is(txErr, errInsufficientBalance) {
+			utils.BadRequest(c, "余额不足，无法预付订阅")
+			return
+		}
+		utils.InternalServerError(c, "创建订阅失败")
+		return
+	}
+
+	sc.respond(c, "订阅已创建", sub.ID)
+}
+
+// Pause 暂停订阅（玩家或服务者）：取消未开始的预约，按未使用场次退款
+func (sc *SubscriptionController) Pause(c *gin.Context) {
+	sc.transition(c, "订阅已暂停", func(tx *gorm.DB, sub *models.Subscription, userID uint) error {
+		if sub.Status != models.SubscriptionStatusActive {
+			return errSubscriptionState
+		}
+		if _, err := releaseUnusedTx(tx, sub, userID, "暂停"); err != nil {
+			return err
+		}
+		now := time.Now()
+		sub.Status = models.SubscriptionStatusPaused
+		sub.PausedAt = &now
+		return nil
+	})
+}
+
+// Resume 恢复订阅（玩家或服务者）：重新预付剩余场次，并从今天起重新排期
+func (sc *SubscriptionController) Resume(c *gin.Context) {
+	sc.transition(c, "订阅已恢复", func(tx *gorm.DB, sub *models.Subscription, userID uint) error {
+		if sub.Status != models.SubscriptionStatusPaused {
+			return errSubscriptionState
+		}
+		remaining := sub.TotalSessions - sub.PaidSessions
+		if remaining > 0 {
+			amount := sub.PricePerSession.Mul(decimal.NewFromInt(int64(remaining)))
+			if _, err := adjustBalanceTx(tx, sub.PlayerID, sub.ProviderID, sub.StudioID, sub.SettleType,
+				amount.Neg(), models.TransactionTypeConsume, userID,
+				fmt.Sprintf("订阅 #%d 恢复预付 · %d 场", sub.ID, remaining)); err != nil {
+				return err
+			}
+			sub.PaidSessions += remaining
+			if err := bookSessionsTx(tx, sub, time.Now(), remaining); err != nil {
+				return err
+			}
+		}
+		sub.Status = models.SubscriptionStatusActive
+		// 暂停期间进行中的最后一场已完成：场次已用完，直接结束订阅
+		if sub.UsedSessions >= sub.TotalSessions {
+			sub.Status = models.SubscriptionStatusCompleted
+		}
+		sub.PausedAt = nil
+		return nil
+	})
+}
+
+// Cancel 取消订阅（玩家或服务者）：取消未开始的预约，按未使用场次退款
+func (sc *SubscriptionController) Cancel(c *gin.Context) {
+	sc.transition(c, "订阅已取消", func(tx *gorm.DB, sub *models.Subscription, userID uint) error {
+		if sub.Status != models.SubscriptionStatusActive && sub.Status != models.SubscriptionStatusPaused {
+			return errSubscriptionState
+		}
+		if _, err := releaseUnusedTx(tx, sub, userID, "取消"); err != nil {
+			return err
+		}
+		now := time.Now()
+		sub.Status = models.SubscriptionStatusCancelled
+		sub.CancelledAt = &now
+		return nil
+	})
+}
+
+// transition 订阅状态流转的公共流程：鉴权（本订阅的玩家或服务者）→ 事务内加锁执行 → 回写
+func (sc *SubscriptionController) transition(c *gin.Context, message string,
+	apply func(tx *gorm.DB, sub *models.Subscription, userID uint) error) {
+
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	subID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid subscription ID")
+		return
+	}
+
+	db := config.GetDB()
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		var sub models.Subscription
+		if err := lockForUpdate(tx).First(&sub, subID).Error; err != nil {
+			return err
+		}
+		if sub.PlayerID != userID && sub.ProviderID != userID {
+			return errForbidden
+		}
+		if err := apply(tx, &sub, userID); err != nil {
+			return err
+		}
+		return tx.Model(&sub).Select("paid_sessions", "refunded_amount", "status", "paused_at", "cancelled_at").
+			Updates(&sub).Error
+	})
+	if txErr != nil {
+		switch {
+		case errors.Is(txErr, gorm.ErrRecordNotFound):
+			utils.NotFound(c, "订阅不存在")
+		case errors.Is(txErr, errForbidden):
+			utils.Forbidden(c, "只有订阅的玩家或服务者可以操作")
+		case errors.Is(txErr, errSubscriptionState):
+			utils.BadRequest(c, txErr.Error())
+		case errors.Is(txErr, errStudioInactive):
+			utils.BadRequest(c, txErr.Error())
+		case errors.Is(txErr, errInsufficientBalance):
+			utils.BadRequest(c, "余额不足，无法预付剩余场次")
+		default:
+			utils.InternalServerError(c, "订阅操作失败")
+		}
+		return
+	}
+
+	sc.respond(c, message, subID)
+}
+
+// respond 回读订阅（含排期记录）并返回
+func (sc *SubscriptionController) respond(c *gin.Context, message string, subID uint) {
+	var sub models.Subscription
+	config.GetDB().Preload("Provider").
+		Preload("Sessions", func(tx *gorm.DB) *gorm.DB { return tx.Order("start_time ASC, id ASC") }).
+		First(&sub, subID)
+	utils.SuccessWithMessage(c, message, sub)
+}
+
+// ListMine 玩家查看自己的订阅
+func (sc *SubscriptionController) ListMine(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+	sc.list(c, "player_id = ?", userID)
+}
+
+// ListHosted 服务者查看自己被订阅的计划
+func (sc *SubscriptionController) ListHosted(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+	sc.list(c, "provider_id = ?", userID)
+}
+
+func (sc *SubscriptionController) list(c *gin.Context, cond string, arg interface{}) {
+	db := config.GetDB()
+	page, pageSize, offset := paginate(c)
+
+	query := db.Model(&models.Subscription{}).Where(cond, arg)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var subs []models.Subscription
+	if err := query.Preload("Player").Preload("Provider").
+		Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&subs).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get subscriptions")
+		return
+	}
+
+	utils.PageSuccess(c, subs, total, page, pageSize)
+}
+
+// useSubscriptionSessionTx 订阅场次完成：已用场次 +1，用满则订阅结束；返回该场单价
+func useSubscriptionSessionTx(tx *gorm.DB, subID uint) (decimal.Decimal, error) {
+	var sub models.Subscription
+	if err := lockForUpdate(tx).First(&sub, subID).Error; err != nil {
+		return decimal.Zero, err
+	}
+	sub.UsedSessions++
+	updates := map[string]interface{}{"used_sessions": sub.UsedSessions}
+	if sub.UsedSessions >= sub.TotalSessions && sub.Status == models.SubscriptionStatusActive {
+		updates["status"] = models.SubscriptionStatusCompleted
+	}
+	return sub.PricePerSession, tx.Model(&sub).Updates(updates).Error
+}
+
+// dropSubscriptionSessionTx 订阅中的单场被取消：退还该场预付，总场次与已付场次各减一
+func dropSubscriptionSessionTx(tx *gorm.DB, subID uint, operatorID uint) error {
+	var sub models.Subscription
+	if err := lockForUpdate(tx).First(&sub, subID).Error; err != nil {
+		return err
+	}
+	if _, err := adjustBalanceTx(tx, sub.PlayerID, sub.ProviderID, sub.StudioID, sub.SettleType,
+		sub.PricePerSession, models.TransactionTypeRefund, operatorID,
+		fmt.Sprintf("订阅 #%d 单场取消退款", sub.ID)); err != nil {
+		return err
+	}
+	sub.TotalSessions--
+	sub.PaidSessions--
+	updates := map[string]interface{}{
+		"total_sessions":  sub.TotalSessions,
+		"paid_sessions":   sub.PaidSessions,
+		"refunded_amount": sub.RefundedAmount.Add(sub.PricePerSession),
+	}
+	if sub.UsedSessions >= sub.TotalSessions && sub.Status == models.SubscriptionStatusActive {
+		updates["status"] = models.SubscriptionStatusCompleted
+	}
+	return tx.Model(&sub).Updates(updates).Error
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/middleware"
+	"companion-platform-backend/models"
+	"companion-platform-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type TipController struct{}
+
+// CreateTipRequest 玩家打赏请求
+type CreateTipRequest struct {
+	Amount      decimal.Decimal    `json:"amount"`
+	Source      models.TipSource   `json:"source" binding:"omitempty,oneof=balance external"` // 默认 balance
+	BalanceType models.BalanceType `json:"balance_type" binding:"omitempty,oneof=money time point"`
+	Message     string             `json:"message" binding:"max=255"`
+}
+
+// Create 玩家对自己已完成的一局打赏服务者：从余额支付时落 tip 流水，站外支付仅登记
+func (tc *TipController) Create(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	recordID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid record ID")
+		return
+	}
+
+	var req CreateTipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if req.Amount.LessThanOrEqual(decimal.Zero) {
+		utils.BadRequest(c, "打赏金额必须大于 0")
+		return
+	}
+	source := req.Source
+	if source == "" {
+		source = models.TipSourceBalance
+	}
+	btype := req.BalanceType
+	if btype == "" {
+		btype = models.BalanceTypeMoney
+	}
+
+	db := config.GetDB()
+	var record models.PlayRecord
+	if err := db.First(&record, recordID).Error; err != nil {
+		utils.NotFound(c, "游玩记录不存在")
+		return
+	}
+	if record.PlayerID != userID {
+		utils.Forbidden(c, "只能打赏自己参与的陪玩")
+		return
+	}
+	if record.Status != models.PlayStatusCompleted {
+		utils.BadRequest(c, "只有已完成的陪玩可以打赏")
+		return
+	}
+
+	tip := models.Tip{
+		PlayRecordID: record.ID,
+		PlayerID:     userID,
+		ProviderID:   record.ProviderID,
+		Source:       source,
+		Amount:       req.Amount,
+		Message:      req.Message,
+	}
+	if source == models.TipSourceBalance {
+		tip.BalanceType = btype
+	}
+
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		if source == models.TipSourceBalance {
+			if _, err := adjustBalanceTx(tx, record.PlayerID, record.ProviderID, record.StudioID, btype,
+				req.Amount.Neg(), models.TransactionTypeTip, userID, "打赏 · "+recordDesc(&record)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&tip).Error; err != nil {
+			return err
+		}
+		return notify(tx, record.ProviderID, models.NotificationTipReceived, "收到打赏",
+			fmt.Sprintf("%s 收到打赏 %s", recordDesc(&record), req.Amount.StringFixed(2)), tip.ID)
+	})
+	if txErr != nil {
+		if errors.Is(txErr, errStudioInactive) {
+			utils.BadRequest(c, txErr.Error())
+			return
+		}
+		if errors.Is(txErr, errInsufficientBalance) {
+			utils.BadRequest(c, "余额不足，无法打赏")
+			return
+		}
+		utils.InternalServerError(c, "打赏失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "打赏成功", tip)
+}
+
+// ListReceived 服务者查看收到的打赏
+func (tc *TipController) ListReceived(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	db := config.GetDB()
+	page, pageSize, offset := paginate(c)
+
+	query := db.Model(&models.Tip{}).Where("provider_id = ?", userID)
+	var total int64
+	query.Count(&total)
+
+	var tips []models.Tip
+	if err := query.Preload("Player").Preload("PlayRecord").
+		Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&tips).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get tips")
+		return
+	}
+
+	utils.PageSuccess(c, tips, total, page, pageSize)
+}
+
+// TipSummaryRow 打赏汇总行（按支付来源与余额类型）
+type TipSummaryRow struct {
+	Source      models.TipSource   `json:"source"`
+	BalanceType models.BalanceType `json:"balance_type"`
+	TotalAmount decimal.Decimal    `json:"total_amount"`
+	Count       int64              `json:"count"`
+}
+
+// tipSummary 服务者收到的打赏汇总
+func tipSummary(db *gorm.DB, providerID uint) []TipSummaryRow {
+	rows := []TipSummaryRow{}
+	db.Model(&models.Tip{}).
+		Select("source, balance_type, COALESCE(SUM(amount),0) as total_amount, COUNT(*) as count").
+		Where("provider_id = ?", providerID).
+		Group("source, balance_type").Scan(&rows)
+	return rows
+}
@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"companion-platform-backend/config"
+	"companion-platform-backend/middleware"
+	"companion-platform-backend/models"
+	"companion-platform-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type StudioTransferController struct{}
+
+// InitiateTransferRequest 发起工作室转让
+type InitiateTransferRequest struct {
+	Username          string           `json:"username" binding:"required"`
+	PreviousOwnerRole models.StaffRole `json:"previous_owner_role" binding:"omitempty,oneof=manager finance reviewer"`
+	Notes             string           `json:"notes" binding:"max=500"`
+}
+
+var errTransferState = errors.New("转让已处理或工作室所有者已变更")
+
+// Initiate 所有者向另一工作室角色账号发起转让（每个工作室同时只能有一笔待确认的转让）
+func (tc *StudioTransferController) Initiate(c *gin.Context) {
+	userID, studio, ok := tc.ownStudio(c)
+	if !ok {
+		return
+	}
+
+	var req InitiateTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var target models.User
+	if err := db.Where("username = ?", strings.TrimSpace(req.Username)).First(&target).Error; err != nil {
+		utils.NotFound(c, "用户不存在")
+		return
+	}
+	if target.Role != models.RoleStudio {
+		utils.BadRequest(c, "只能转让给工作室角色账号")
+		return
+	}
+	if target.ID == userID {
+		utils.BadRequest(c, "不能转让给自己")
+		return
+	}
+	var pending int64
+	db.Model(&models.StudioTransfer{}).Where("studio_id = ? AND status = ?", studio.ID, models.TransferPending).Count(&pending)
+	if pending > 0 {
+		utils.BadRequest(c, "该工作室已有待确认的转让")
+		return
+	}
+
+	transfer := models.StudioTransfer{
+		StudioID:          studio.ID,
+		FromUserID:        userID,
+		ToUserID:          target.ID,
+		Status:            models.TransferPending,
+		PreviousOwnerRole: req.PreviousOwnerRole,
+		Notes:             req.Notes,
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+		return notify(tx, target.ID, models.NotificationStudioRelation,
+			"工作室转让待确认", "「"+studio.Name+"」的所有者希望将工作室转让给你", transfer.ID)
+	}); err != nil {
+		utils.InternalServerError(c, "Failed to create transfer")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "转让已发起，等待对方确认", transfer)
+}
+
+// Cancel 所有者撤回待确认的转让
+func (tc *StudioTransferController) Cancel(c *gin.Context) {
+	userID, studio, ok := tc.ownStudio(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	res := config.GetDB().Model(&models.StudioTransfer{}).
+		Where("studio_id = ? AND from_user_id = ? AND status = ?", studio.ID, userID, models.TransferPending).
+		Updates(map[string]interface{}{"status": models.TransferCancelled, "responded_at": &now})
+	if res.Error != nil {
+		utils.InternalServerError(c, "Failed to cancel transfer")
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.NotFound(c, "没有待确认的转让")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "转让已撤回", nil)
+}
+
+// History 工作室的转让历史（任何员工可查看）
+func (tc *StudioTransferController) History(c *gin.Context) {
+	_, studio, ok := authorizeStudio(c, models.PermStudioView)
+	if !ok {
+		return
+	}
+
+	var transfers []models.StudioTransfer
+	if err := config.GetDB().Where("studio_id = ?", studio.ID).Preload("FromUser").Preload("ToUser").
+		Order("created_at DESC, id DESC").Find(&transfers).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get transfers")
+		return
+	}
+
+	utils.Success(c, transfers)
+}
+
+// Incoming 当前用户收到的待确认转让
+func (tc *StudioTransferController) Incoming(c *gin.Context) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+
+	var transfers []models.StudioTransfer
+	if err := config.GetDB().Where("to_user_id = ? AND status = ?", userID, models.TransferPending).
+		Preload("Studio").Preload("FromUser").Order("created_at DESC").Find(&transfers).Error; err != nil {
+		utils.InternalServerError(c, "Failed to get transfers")
+		return
+	}
+
+	utils.Success(c, transfers)
+}
+
+// Accept 接收方确认转让：所有权转移，接收方原员工身份并入所有者，原所有者按约定留任或离开，相关权限缓存失效
+func (tc *StudioTransferController) Accept(c *gin.Context) {
+	tc.respond(c, true)
+}
+
+// Decline 接收方拒绝转让
+func (tc *StudioTransferController) Decline(c *gin.Context) {
+	tc.respond(c, false)
+}
+
+func (tc *StudioTransferController) respond(c *gin.Context, accept bool) {
+	userID, err := middleware.GetCurrentUserID(c)
+	if err != nil {
+		utils.Unauthorized(c, "User not found")
+		return
+	}
+	transferID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid transfer ID")
+		return
+	}
+	var req struct {
+		Notes string `json:"notes" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	db := config.GetDB()
+	var transfer models.StudioTransfer
+	if err := db.Where("to_user_id = ?", userID).First(&transfer, transferID).Error; err != nil {
+		utils.NotFound(c, "转让不存在")
+		return
+	}
+
+	now := time.Now()
+	status := models.TransferDeclined
+	if accept {
+		status = models.TransferAccepted
+	}
+	var studio models.Studio
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.StudioTransfer{}).Where("id = ? AND status = ?", transfer.ID, models.TransferPending).
+			Updates(map[string]interface{}{"status": status, "responded_at": &now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errTransferState
+		}
+		if err := lockForUpdate(tx).First(&studio, transfer.StudioID).Error; err != nil {
+			return err
+		}
+		if !accept {
+			return notify(tx, transfer.FromUserID, models.NotificationStudioRelation,
+				"工作室转让被拒绝", withReason("「"+studio.Name+"」的转让已被对方拒绝", req.Notes), transfer.ID)
+		}
+
+		if studio.OwnerID != transfer.FromUserID {
+			return errTransferState
+		}
+		if err := tx.Model(&studio).Update("owner_id", userID).Error; err != nil {
+			return err
+		}
+		// 新所有者拥有全部权限，不再保留员工身份；原所有者按约定留任
+		if err := tx.Where("studio_id = ? AND user_id = ?", studio.ID, userID).Delete(&models.StudioStaff{}).Error; err != nil {
+			return err
+		}
+		if transfer.PreviousOwnerRole != "" {
+			if err := tx.Create(&models.StudioStaff{
+				StudioID:  studio.ID,
+				UserID:    transfer.FromUserID,
+				Role:      transfer.PreviousOwnerRole,
+				CreatedBy: userID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return notify(tx, transfer.FromUserID, models.NotificationStudioRelation,
+			"工作室转让已完成", "「"+studio.Name+"」已转让给新所有者", transfer.ID)
+	})
+	if txErr != nil {
+		switch {
+		case errors.Is(txErr, errTransferState):
+			utils.BadRequest(c, txErr.Error())
+		default:
+			utils.InternalServerError(c, "Failed to process transfer")
+		}
+		return
+	}
+	if accept {
+		invalidateStudioAccess(studio.ID, transfer.FromUserID)
+		invalidateStudioAccess(studio.ID, userID)
+	}
+
+	db.Preload("Studio").First(&transfer, transfer.ID)
+	message := "已拒绝转让"
+	if accept {
+		message = "已接收工作室"
+	}
+	utils.SuccessWithMessage(c, message, transfer)
+}
+
+// ownStudio 加载路由 :id 指定的工作室，并校验当前用户是其所有者（转让仅所有者可发起）
+func (tc *StudioTransferController) ownStudio(c *gin.Context) (uint, *models.Studio, bool) {
+	userID, studio, ok := authorizeStudio(c, models.PermStudioStaff)
+	if !ok {
+		return 0, nil, false
+	}
+	if studio.OwnerID != userID {
+		utils.Forbidden(c, "只有工作室所有者可以转让工作室")
+		return 0, nil, false
+	}
+	return userID, studio, true
+}
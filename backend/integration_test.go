@@ -9,11 +9,13 @@ import (
 	"net/http/httptest"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"companion-platform-backend/config"
+	"companion-platform-backend/controllers"
 	"companion-platform-backend/models"
 	"companion-platform-backend/routes"
 	"companion-platform-backend/utils"
@@ -41,14 +43,17 @@ func newTestApp(t *testing.T) *gin.Engine {
 	}
 	sqlDB.SetMaxOpenConns(1) // SQLite 单写者：串行化写入，避免 "database is locked"
 
-	if err := gdb.AutoMigrate(
-		&models.User{}, &models.Studio{}, &models.ProviderStudioRelation{},
-		&models.Balance{}, &models.BalanceTransaction{}, &models.PlayRecord{}, &models.Review{},
-	); err != nil {
+	config.DB = gdb
+	if err := config.AutoMigrate(); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
+	if err := config.SeedGameCatalog(); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if err := config.SeedReviewTags(); err != nil {
+		t.Fatalf("seed tags: %v", err)
+	}
 
-	config.DB = gdb
 	utils.InitJWT("test-secret")
 	utils.InitCache(5*time.Minute, 10*time.Minute)
 	gin.SetMode(gin.TestMode)
@@ -91,6 +96,17 @@ func register(t *testing.T, r *gin.Engine, role, uname, nick string) (token stri
 	return
 }
 
+// registerAdmin 注册账号后经 SeedAdmins 提升为平台管理员，重新登录取得管理员 token
+func registerAdmin(t *testing.T, r *gin.Engine, uname, nick string) string {
+	t.Helper()
+	register(t, r, "player", uname, nick)
+	if err := config.SeedAdmins([]string{uname}); err != nil {
+		t.Fatalf("seed admin: %v", err)
+	}
+	_, resp := doReq(t, r, "POST", "/api/v1/login", "", map[string]any{"username": uname, "password": "pass123"})
+	return mustData(t, resp)["token"].(string)
+}
+
 func mustData(t *testing.T, resp map[string]any) map[string]any {
 	t.Helper()
 	d, ok := resp["data"].(map[string]any)
@@ -244,75 +260,2003 @@ func TestPlayRecordSettlement(t *testing.T) {
 	}
 }
 
-// --- 用户故事 4：服务者申请加入工作室，工作室审批 ---
+// --- 用户故事 3b：完成后玩家确认 / 申诉，服务者处理申诉 ---
 
-func TestStudioApprovalFlow(t *testing.T) {
+func TestPlayRecordConfirmAndDispute(t *testing.T) {
 	r := newTestApp(t)
-	vtok, _ := register(t, r, "provider", "prov3", "晚风")
-	stok, _ := register(t, r, "studio", "studio1", "星轨")
+	ptok, pid := register(t, r, "player", "player6", "小柚")
+	vtok, vid := register(t, r, "provider", "prov6", "晚风")
 
-	// 工作室创建
-	_, resp := doReq(t, r, "POST", "/api/v1/studio/", stok, map[string]any{"name": "星轨陪玩", "description": "FPS/MOBA"})
-	sid := uint(mustData(t, resp)["id"].(float64))
+	doReq(t, r, "POST", "/api/v1/provider/balances", vtok, map[string]any{
+		"player_id": pid, "provider_id": vid, "type": "money", "amount": 100.00,
+	})
 
-	// 服务者申请
-	code, resp := doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", sid), vtok, map[string]any{"notes": "想加入"})
-	if code != http.StatusOK || resp["code"].(float64) != 0 {
-		t.Fatalf("apply failed: %d %v", code, resp)
+	startAndComplete := func(amount float64) uint {
+		_, resp := doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{
+			"player_id": pid, "game_name": "王者荣耀",
+		})
+		id := uint(mustData(t, resp)["id"].(float64))
+		_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/complete", id), vtok, map[string]any{
+			"duration": 60, "amount": amount,
+		})
+		if mustData(t, resp)["confirm_status"] != "pending" {
+			t.Fatalf("completed record confirm_status = %v, want pending", mustData(t, resp)["confirm_status"])
+		}
+		return id
+	}
+	balanceOf := func() map[string]any {
+		_, resp := doReq(t, r, "GET", fmt.Sprintf("/api/v1/player/balances/provider/%d", vid), ptok, nil)
+		return resp["data"].([]any)[0].(map[string]any)
 	}
 
-	// 工作室看到 1 条待审批
-	_, resp = doReq(t, r, "GET", "/api/v1/studio/dashboard", stok, nil)
-	if mustData(t, resp)["pending_count"].(float64) != 1 {
-		t.Fatalf("pending_count = %v, want 1", mustData(t, resp)["pending_count"])
+	// 玩家确认
+	id1 := startAndComplete(10)
+	_, resp := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/player/records/%d/confirm", id1), ptok, nil)
+	if mustData(t, resp)["confirm_status"] != "confirmed" {
+		t.Fatalf("confirm_status = %v, want confirmed", mustData(t, resp)["confirm_status"])
 	}
 
-	// 取出申请并批准
-	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/studio/%d/applications", sid), stok, nil)
+	// 申诉：已扣 40 进入冻结
+	id2 := startAndComplete(40)
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/player/records/%d/dispute", id2), ptok, map[string]any{"reason": "没打完就结束了"})
+	if mustData(t, resp)["confirm_status"] != "disputed" {
+		t.Fatalf("confirm_status = %v, want disputed", mustData(t, resp)["confirm_status"])
+	}
+	if b := balanceOf(); decFloat(b["amount"]) != 50 || decFloat(b["frozen_amount"]) != 40 {
+		t.Fatalf("after dispute amount/frozen = %v/%v, want 50/40", b["amount"], b["frozen_amount"])
+	}
+	// 申诉后再确认失败，记录保持申诉中，冻结额留待处理
+	if _, resp := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/player/records/%d/confirm", id2), ptok, nil); resp["code"].(float64) == 0 {
+		t.Fatal("confirm after dispute should fail")
+	}
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/provider/play-records/%d", id2), vtok, nil)
+	if d := mustData(t, resp); d["confirm_status"] != "disputed" {
+		t.Fatalf("confirm_status after confirm attempt = %v, want disputed", d["confirm_status"])
+	}
+
+	// 部分退款 15：解冻 40，退回 15
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/resolve", id2), vtok, map[string]any{
+		"resolution": "partial", "refund_amount": 15, "notes": "补偿半局",
+	})
+	if mustData(t, resp)["confirm_status"] != "resolved" {
+		t.Fatalf("resolve failed: %v", resp)
+	}
+	if b := balanceOf(); decFloat(b["amount"]) != 65 || decFloat(b["frozen_amount"]) != 0 {
+		t.Fatalf("after partial refund amount/frozen = %v/%v, want 65/0", b["amount"], b["frozen_amount"])
+	}
+
+	// 已处理的申诉不能再次处理
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/resolve", id2), vtok, map[string]any{"resolution": "refund"})
+	if resp["code"].(float64) == 0 {
+		t.Fatal("resolving twice should fail")
+	}
+
+	// 窗口到期自动确认
+	id3 := startAndComplete(5)
+	if _, err := controllers.AutoConfirmExpired(time.Now().Add(25 * time.Hour)); err != nil {
+		t.Fatalf("auto confirm: %v", err)
+	}
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/player/records/%d/dispute", id3), ptok, map[string]any{"reason": "晚了"})
+	if resp["code"].(float64) == 0 {
+		t.Fatal("dispute after auto-confirm should fail")
+	}
+}
+
+// --- 用户故事 3c：长时间未结束的陪玩被自动取消并通知服务者 ---
+
+func TestStaleActiveRecordsAutoClosed(t *testing.T) {
+	r := newTestApp(t)
+	ptok, pid := register(t, r, "player", "player7", "小柚")
+	vtok, vid := register(t, r, "provider", "prov7", "晚风")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{
+		"player_id": pid, "game_name": "王者荣耀",
+	})
+	recID := uint(mustData(t, resp)["id"].(float64))
+
+	// 订阅场次：预付 2 × 10，开始第一场后滞留
+	doReq(t, r, "POST", "/api/v1/provider/balances", vtok, map[string]any{
+		"player_id": pid, "provider_id": vid, "type": "money", "amount": 100.00,
+	})
+	_, resp = doReq(t, r, "POST", "/api/v1/player/subscriptions", ptok, map[string]any{
+		"provider_id": vid, "game_name": "王者荣耀", "weekdays": []int{0, 1, 2, 3, 4, 5, 6},
+		"time_of_day": "20:00", "price_per_session": 10, "total_sessions": 2,
+	})
+	sub := mustData(t, resp)
+	subRecID := uint(sub["sessions"].([]any)[0].(map[string]any)["id"].(float64))
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/start", subRecID), vtok, nil)
+
+	// 未超时：不处理
+	if n, _ := controllers.CloseStaleActive(time.Now()); n != 0 {
+		t.Fatalf("fresh record should not be closed, handled = %d", n)
+	}
+
+	n, err := controllers.CloseStaleActive(time.Now().Add(13 * time.Hour))
+	if err != nil || n != 2 {
+		t.Fatalf("CloseStaleActive = %d, %v; want 2, nil", n, err)
+	}
+	// 重复执行不会重复处理
+	if n, _ := controllers.CloseStaleActive(time.Now().Add(14 * time.Hour)); n != 0 {
+		t.Fatalf("stale record handled twice, handled = %d", n)
+	}
+
+	_, resp = doReq(t, r, "GET", "/api/v1/player/dashboard", ptok, nil)
+	if ongoing := mustData(t, resp)["ongoing_records"].([]any); len(ongoing) != 0 {
+		t.Fatalf("ongoing_records = %d, want 0", len(ongoing))
+	}
+
+	// 自动取消的订阅场次退还预付款：100 - 20 + 10
+	_, resp = doReq(t, r, "GET", "/api/v1/player/dashboard", ptok, nil)
+	if got := decFloat(mustData(t, resp)["money_total"]); got != 90 {
+		t.Fatalf("money after stale subscription session = %v, want 90", got)
+	}
+	_, resp = doReq(t, r, "GET", "/api/v1/player/subscriptions", ptok, nil)
+	if sub := mustData(t, resp)["list"].([]any)[0].(map[string]any); sub["total_sessions"].(float64) != 1 || sub["paid_sessions"].(float64) != 1 {
+		t.Fatalf("subscription after stale cancel = %v", sub)
+	}
+
+	_, resp = doReq(t, r, "GET", "/api/v1/notifications?unread=1", vtok, nil)
 	list := mustData(t, resp)["list"].([]any)
-	relID := uint(list[0].(map[string]any)["id"].(float64))
-	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/applications/%d", relID), stok, map[string]any{"status": "approved"})
+	stale := map[uint]bool{}
+	for _, n := range list {
+		if n := n.(map[string]any); n["type"] == "play_record_stale" {
+			stale[uint(n["ref_id"].(float64))] = true
+		}
+	}
+	if len(stale) != 2 || !stale[recID] || !stale[subRecID] {
+		t.Fatalf("provider notifications = %v, want stale for records %d and %d", list, recID, subRecID)
+	}
+	doReq(t, r, "PUT", "/api/v1/notifications/read-all", vtok, nil)
+	_, resp = doReq(t, r, "GET", "/api/v1/notifications?unread=1", vtok, nil)
+	if mustData(t, resp)["total"].(float64) != 0 {
+		t.Fatal("notifications should all be read")
+	}
+}
+
+// --- 用户故事 3d：完成后修正时长/数额，差额自动补扣或退回 ---
+
+func TestPlayRecordAmend(t *testing.T) {
+	r := newTestApp(t)
+	ptok, pid := register(t, r, "player", "player8", "小柚")
+	vtok, vid := register(t, r, "provider", "prov8", "晚风")
+
+	doReq(t, r, "POST", "/api/v1/provider/balances", vtok, map[string]any{
+		"player_id": pid, "provider_id": vid, "type": "money", "amount": 100.00,
+	})
+	_, resp := doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{
+		"player_id": pid, "game_name": "王者荣耀",
+	})
+	recID := uint(mustData(t, resp)["id"].(float64))
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/complete", recID), vtok, map[string]any{
+		"duration": 60, "amount": 40.00,
+	})
+	moneyTotal := func() float64 {
+		_, resp := doReq(t, r, "GET", "/api/v1/player/dashboard", ptok, nil)
+		return decFloat(mustData(t, resp)["money_total"])
+	}
+
+	// 40 → 30：退回 10
+	amendURL := fmt.Sprintf("/api/v1/provider/play-records/%d/amend", recID)
+	_, resp = doReq(t, r, "PUT", amendURL, vtok, map[string]any{"amount": 30, "reason": "手误多记了"})
+	if decFloat(mustData(t, resp)["amount"]) != 30 {
+		t.Fatalf("amend failed: %v", resp)
+	}
+	if got := moneyTotal(); got != 70 {
+		t.Fatalf("money after amend down = %v, want 70", got)
+	}
+
+	// 30 → 45，时长 60 → 90：补扣 15
+	_, resp = doReq(t, r, "PUT", amendURL, vtok, map[string]any{"amount": 45, "duration": 90, "reason": "加时"})
+	if d := mustData(t, resp); d["duration"].(float64) != 90 || len(d["revisions"].([]any)) != 2 {
+		t.Fatalf("amend result = %v", d)
+	}
+	if got := moneyTotal(); got != 55 {
+		t.Fatalf("money after amend up = %v, want 55", got)
+	}
+
+	// 补扣超过余额应失败
+	_, resp = doReq(t, r, "PUT", amendURL, vtok, map[string]any{"amount": 500, "reason": "超额"})
+	if resp["code"].(float64) == 0 {
+		t.Fatal("amend beyond balance should fail")
+	}
+
+	// 45 → 0 → 20：改为 0 后仍按已结算记录联动，再改回会重新扣费
+	doReq(t, r, "PUT", amendURL, vtok, map[string]any{"amount": 0, "reason": "免单"})
+	if got := moneyTotal(); got != 100 {
+		t.Fatalf("money after amend to zero = %v, want 100", got)
+	}
+	doReq(t, r, "PUT", amendURL, vtok, map[string]any{"amount": 20, "reason": "改为半价"})
+	if got := moneyTotal(); got != 80 {
+		t.Fatalf("money after amend from zero = %v, want 80", got)
+	}
+
+	// 玩家可查看修订历史
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/player/records/%d/revisions", recID), ptok, nil)
+	if revs := resp["data"].([]any); len(revs) != 4 {
+		t.Fatalf("revisions = %d, want 4", len(revs))
+	}
+}
+
+// --- 用户故事 3e：游戏目录（别名归一、校验、历史记录映射） ---
+
+func TestGameCatalog(t *testing.T) {
+	r := newTestApp(t)
+	_, pid := register(t, r, "player", "player9", "小柚")
+	vtok, vid := register(t, r, "provider", "prov9", "晚风")
+	atok := registerAdmin(t, r, "admin9", "管理员")
+
+	// 别名 + 大小写不敏感，落规范名称
+	_, resp := doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{
+		"player_id": pid, "game_name": "WZRY", "game_mode": "巅峰赛",
+	})
+	d := mustData(t, resp)
+	if d["game_name"] != "王者荣耀" || d["game_mode"] != "巅峰赛" || d["game_id"].(float64) == 0 {
+		t.Fatalf("record game = %v/%v (id %v), want 王者荣耀/巅峰赛", d["game_name"], d["game_mode"], d["game_id"])
+	}
+
+	// 目录外的游戏、模式被拒绝
+	_, resp = doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{"player_id": pid, "game_name": "不存在的游戏"})
+	if resp["code"].(float64) == 0 {
+		t.Fatal("unknown game should be rejected")
+	}
+	_, resp = doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{"player_id": pid, "game_name": "王者", "game_mode": "乱斗"})
+	if resp["code"].(float64) == 0 {
+		t.Fatal("unknown mode should be rejected")
+	}
+
+	// 管理员新增游戏与模式别名（工作室账号无权维护目录）
+	stok, _ := register(t, r, "studio", "studio9", "星轨")
+	if code, _ := doReq(t, r, "POST", "/api/v1/admin/games", stok, map[string]any{"name": "扫雷"}); code != http.StatusForbidden {
+		t.Fatalf("game creation by studio = %d, want 403", code)
+	}
+	// 用户列表仍对工作室账号开放
+	if code, _ := doReq(t, r, "GET", "/api/v1/admin/users", stok, nil); code != http.StatusOK {
+		t.Fatalf("user list by studio = %d, want 200", code)
+	}
+	// ADMIN_USERNAMES 只提升玩家账号，服务者账号保持原角色
+	if err := config.SeedAdmins([]string{"prov9"}); err != nil {
+		t.Fatalf("seed admins: %v", err)
+	}
+	var prov models.User
+	config.DB.First(&prov, vid)
+	if prov.Role != models.RoleProvider {
+		t.Fatalf("provider promoted by SeedAdmins, role = %q", prov.Role)
+	}
+	// 空白模式名被拒绝；重复模式名（去空白、不区分大小写）只保留一个
+	if code, _ := doReq(t, r, "POST", "/api/v1/admin/games", atok, map[string]any{"name": "扫雷", "modes": []string{"经典", " "}}); code != http.StatusBadRequest {
+		t.Fatalf("blank mode name = %d, want 400", code)
+	}
+	_, resp = doReq(t, r, "POST", "/api/v1/admin/games", atok, map[string]any{
+		"name": "金铲铲之战", "modes": []string{"排位", " 排位 ", "匹配"}, "aliases": []string{"金铲铲"},
+	})
+	created := mustData(t, resp)
+	gameID := uint(created["id"].(float64))
+	if modes := created["modes"].([]any); len(modes) != 2 {
+		t.Fatalf("created modes = %v, want 2 after dedupe", modes)
+	}
+	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/admin/games/%d/aliases", gameID), atok, map[string]any{"alias": "王者"})
+	if resp["code"].(float64) == 0 {
+		t.Fatal("alias already used by another game should be rejected")
+	}
+	// 改名同样不能与其他游戏的名称或别名冲突
+	for _, name := range []string{"王者", "王者荣耀"} {
+		_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/admin/games/%d", gameID), atok, map[string]any{"name": name})
+		if resp["code"].(float64) == 0 {
+			t.Fatalf("renaming to %q should be rejected", name)
+		}
+	}
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/admin/games/%d", gameID), atok, map[string]any{"name": "金铲铲之战S"})
+	if mustData(t, resp)["name"] != "金铲铲之战S" {
+		t.Fatalf("rename = %v", resp)
+	}
+
+	// 历史自由文本记录映射到目录（已停用的游戏同样可以映射）
+	config.DB.Model(&models.Game{}).Where("id = ?", gameID).Update("is_active", false)
+	for _, name := range []string{"王者", "wzry", "金铲铲", "扫雷"} {
+		config.DB.Create(&models.PlayRecord{PlayerID: pid, ProviderID: vid, GameName: name, StartTime: time.Now(), Status: models.PlayStatusCancelled})
+	}
+	report, err := controllers.MapPlayRecordsToCatalog(config.DB)
+	if err != nil {
+		t.Fatalf("map catalog: %v", err)
+	}
+	if report.Mapped != 3 || len(report.UnmatchedGames) != 1 || report.UnmatchedGames[0] != "扫雷" {
+		t.Fatalf("mapping report = %+v, want 3 mapped and 扫雷 unmatched", report)
+	}
+	var wz int64
+	config.DB.Model(&models.PlayRecord{}).Where("game_name = ?", "王者荣耀").Count(&wz)
+	if wz != 3 {
+		t.Fatalf("王者荣耀 records = %d, want 3", wz)
+	}
+}
+
+// --- 用户故事 3f：玩家打赏，服务者统计单列 ---
+
+func TestTips(t *testing.T) {
+	r := newTestApp(t)
+	ptok, pid := register(t, r, "player", "player10", "小柚")
+	vtok, vid := register(t, r, "provider", "prov10", "晚风")
+
+	doReq(t, r, "POST", "/api/v1/provider/balances", vtok, map[string]any{
+		"player_id": pid, "provider_id": vid, "type": "money", "amount": 50.00,
+	})
+	_, resp := doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{"player_id": pid, "game_name": "王者荣耀"})
+	recID := uint(mustData(t, resp)["id"].(float64))
+	tipURL := fmt.Sprintf("/api/v1/player/records/%d/tips", recID)
+
+	// 进行中的一局不能打赏
+	_, resp = doReq(t, r, "POST", tipURL, ptok, map[string]any{"amount": 5})
+	if resp["code"].(float64) == 0 {
+		t.Fatal("tipping an active record should fail")
+	}
+
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/complete", recID), vtok, map[string]any{"amount": 20})
+
+	// 余额打赏 10 → 余额 20，外部打赏 30 不动余额
+	_, resp = doReq(t, r, "POST", tipURL, ptok, map[string]any{"amount": 10, "message": "打得好"})
 	if resp["code"].(float64) != 0 {
-		t.Fatalf("approve failed: %v", resp)
+		t.Fatalf("balance tip failed: %v", resp)
+	}
+	doReq(t, r, "POST", tipURL, ptok, map[string]any{"amount": 30, "source": "external"})
+	_, resp = doReq(t, r, "POST", tipURL, ptok, map[string]any{"amount": 100})
+	if resp["code"].(float64) == 0 {
+		t.Fatal("tip beyond balance should fail")
 	}
 
-	// 成员列表出现该服务者
-	_, resp = doReq(t, r, "GET", "/api/v1/studio/members", stok, nil)
-	members := resp["data"].([]any)
-	if len(members) != 1 {
-		t.Fatalf("members = %d, want 1", len(members))
+	_, resp = doReq(t, r, "GET", "/api/v1/player/dashboard", ptok, nil)
+	if got := decFloat(mustData(t, resp)["money_total"]); got != 20 {
+		t.Fatalf("money after tip = %v, want 20", got)
 	}
 
-	// 重复申请应失败
-	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", sid), vtok, map[string]any{"notes": "再申请"})
+	_, resp = doReq(t, r, "GET", "/api/v1/provider/dashboard", vtok, nil)
+	var tipTotal float64
+	for _, row := range mustData(t, resp)["tips"].([]any) {
+		tipTotal += decFloat(row.(map[string]any)["total_amount"])
+	}
+	if tipTotal != 40 {
+		t.Fatalf("provider tip total = %v, want 40", tipTotal)
+	}
+
+	_, resp = doReq(t, r, "GET", "/api/v1/provider/tips", vtok, nil)
+	if mustData(t, resp)["total"].(float64) != 2 {
+		t.Fatalf("received tips = %v, want 2", mustData(t, resp)["total"])
+	}
+}
+
+// --- 用户故事 3g：进行中时间线事件与心跳，无活动标记 ---
+
+func TestPlayRecordTimeline(t *testing.T) {
+	r := newTestApp(t)
+	ptok, pid := register(t, r, "player", "player11", "小柚")
+	vtok, _ := register(t, r, "provider", "prov11", "晚风")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{"player_id": pid, "game_name": "王者荣耀"})
+	recID := uint(mustData(t, resp)["id"].(float64))
+
+	// 未启用监测（无事件/心跳）的记录不会被标记
+	if n, _ := controllers.FlagIdleActive(time.Now().Add(time.Hour)); n != 0 {
+		t.Fatalf("record without heartbeat flagged, n = %d", n)
+	}
+
+	for _, ev := range []map[string]any{
+		{"type": "match_start", "content": "第一局"},
+		{"type": "match_result", "content": "胜利"},
+	} {
+		_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/provider/play-records/%d/events", recID), vtok, ev)
+		if resp["code"].(float64) != 0 {
+			t.Fatalf("post event failed: %v", resp)
+		}
+	}
+	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/provider/play-records/%d/events", recID), vtok, map[string]any{"type": "bogus"})
 	if resp["code"].(float64) == 0 {
-		t.Fatal("duplicate application should fail")
+		t.Fatal("unknown event type should be rejected")
+	}
+
+	// 玩家在控制台与详情里都能看到时间线
+	_, resp = doReq(t, r, "GET", "/api/v1/player/dashboard", ptok, nil)
+	ongoing := mustData(t, resp)["ongoing_records"].([]any)
+	if evs := ongoing[0].(map[string]any)["events"].([]any); len(evs) != 2 {
+		t.Fatalf("dashboard events = %d, want 2", len(evs))
+	}
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/player/records/%d", recID), ptok, nil)
+	if evs := mustData(t, resp)["events"].([]any); evs[1].(map[string]any)["content"] != "胜利" {
+		t.Fatalf("detail events = %v", evs)
+	}
+
+	// 静默超时被标记，心跳后清除
+	if n, _ := controllers.FlagIdleActive(time.Now().Add(time.Hour)); n != 1 {
+		t.Fatalf("idle flagged = %d, want 1", n)
+	}
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/provider/play-records/%d", recID), vtok, nil)
+	if mustData(t, resp)["idle_at"] == nil {
+		t.Fatal("record should be flagged idle")
+	}
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/heartbeat", recID), vtok, nil)
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/provider/play-records/%d", recID), vtok, nil)
+	if mustData(t, resp)["idle_at"] != nil {
+		t.Fatal("heartbeat should clear idle flag")
 	}
 }
 
-// --- 用户故事 5：玩家评价服务者，评分汇总 ---
+// --- 用户故事 3h：对局战绩与胜率统计 ---
 
-func TestReviewFlow(t *testing.T) {
+func TestMatchOutcomeStats(t *testing.T) {
 	r := newTestApp(t)
-	ptok, _ := register(t, r, "player", "player4", "小柚")
-	_, vid := register(t, r, "provider", "prov4", "晚风")
+	_, pid := register(t, r, "player", "player12", "小柚")
+	vtok, vid := register(t, r, "provider", "prov12", "晚风")
 
-	for _, rating := range []int{5, 4} {
-		_, resp := doReq(t, r, "POST", "/api/v1/player/reviews", ptok, map[string]any{
-			"target_type": "provider", "target_id": vid, "rating": rating, "content": "不错",
-		})
+	_, resp := doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{"player_id": pid, "game_name": "王者荣耀", "game_mode": "排位赛"})
+	recID := uint(mustData(t, resp)["id"].(float64))
+	outcomeURL := fmt.Sprintf("/api/v1/provider/play-records/%d/outcomes", recID)
+	for _, o := range []map[string]any{
+		{"result": "win", "rank_before": "钻石III", "rank_after": "钻石II", "stars_gained": 2},
+		{"result": "win", "stars_gained": 1},
+		{"result": "loss", "stars_gained": -1},
+	} {
+		_, resp = doReq(t, r, "POST", outcomeURL, vtok, o)
 		if resp["code"].(float64) != 0 {
-			t.Fatalf("create review failed: %v", resp)
+			t.Fatalf("add outcome failed: %v", resp)
 		}
 	}
+	// 误登记后删除
+	_, resp = doReq(t, r, "POST", outcomeURL, vtok, map[string]any{"result": "loss"})
+	wrongID := uint(mustData(t, resp)["id"].(float64))
+	_, resp = doReq(t, r, "DELETE", fmt.Sprintf("/api/v1/provider/play-records/outcomes/%d", wrongID), vtok, nil)
+	if resp["code"].(float64) != 0 {
+		t.Fatalf("delete outcome failed: %v", resp)
+	}
 
-	_, resp := doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews/summary?target_type=provider&target_id=%d", vid), "", nil)
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/providers/%d", vid), "", nil)
+	stats := mustData(t, resp)["match_stats"].(map[string]any)
+	if stats["matches"].(float64) != 3 || stats["wins"].(float64) != 2 {
+		t.Fatalf("match stats = %v, want 3 matches 2 wins", stats)
+	}
+	if wr := stats["win_rate"].(float64); wr < 0.66 || wr > 0.67 {
+		t.Fatalf("win_rate = %v, want ~0.667", wr)
+	}
+	byGame := stats["by_game"].([]any)
+	if len(byGame) != 1 || byGame[0].(map[string]any)["game_name"] != "王者荣耀" {
+		t.Fatalf("by_game = %v", byGame)
+	}
+	if avg := byGame[0].(map[string]any)["avg_stars_gained"].(float64); avg < 0.66 || avg > 0.67 {
+		t.Fatalf("avg_stars_gained = %v, want ~0.667", avg)
+	}
+	// 段位变化：只有第一场登记了段位，钻石III → 钻石II 升一段
+	if stats["ranked_matches"].(float64) != 1 || stats["avg_rank_gained"].(float64) != 1 {
+		t.Fatalf("rank stats = %v/%v, want 1 ranked match gaining 1", stats["ranked_matches"], stats["avg_rank_gained"])
+	}
+
+	// 已取消记录上的对局不计入统计
+	_, resp = doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{"player_id": pid, "game_name": "王者荣耀"})
+	cancelID := uint(mustData(t, resp)["id"].(float64))
+	for i := 0; i < 3; i++ {
+		doReq(t, r, "POST", fmt.Sprintf("/api/v1/provider/play-records/%d/outcomes", cancelID), vtok, map[string]any{"result": "win", "stars_gained": 1})
+	}
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/cancel", cancelID), vtok, nil)
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/providers/%d", vid), "", nil)
+	if stats = mustData(t, resp)["match_stats"].(map[string]any); stats["matches"].(float64) != 3 {
+		t.Fatalf("matches after cancelling padded record = %v, want 3", stats["matches"])
+	}
+}
+
+// --- 用户故事 3i：游玩记录筛选、排序、汇总与 CSV 导出 ---
+
+func TestPlayRecordFilteringAndExport(t *testing.T) {
+	r := newTestApp(t)
+	ptok, pid := register(t, r, "player", "player13", "小柚")
+	vtok, vid := register(t, r, "provider", "prov13", "晚风")
+
+	doReq(t, r, "POST", "/api/v1/provider/balances", vtok, map[string]any{
+		"player_id": pid, "provider_id": vid, "type": "money", "amount": 500.00,
+	})
+	for _, s := range []struct {
+		game     string
+		duration int
+		amount   float64
+	}{{"王者荣耀", 30, 20}, {"wzry", 60, 45}, {"英雄联盟", 90, 80}} {
+		_, resp := doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{"player_id": pid, "game_name": s.game})
+		id := uint(mustData(t, resp)["id"].(float64))
+		doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/complete", id), vtok, map[string]any{
+			"duration": s.duration, "amount": s.amount,
+		})
+	}
+
+	// 按游戏（别名）筛选 + 汇总
+	_, resp := doReq(t, r, "GET", "/api/v1/player/records?game=王者&status=completed", ptok, nil)
 	d := mustData(t, resp)
-	if d["count"].(float64) != 2 {
-		t.Fatalf("review count = %v, want 2", d["count"])
+	if d["total"].(float64) != 2 {
+		t.Fatalf("filtered total = %v, want 2", d["total"])
 	}
-	if avg := d["average_rating"].(float64); avg < 4.4 || avg > 4.6 {
-		t.Fatalf("average_rating = %v, want ~4.5", avg)
+	summary := d["summary"].(map[string]any)
+	if summary["total_duration"].(float64) != 90 {
+		t.Fatalf("total_duration = %v, want 90", summary["total_duration"])
+	}
+	if got := decFloat(summary["by_settle_type"].([]any)[0].(map[string]any)["total_amount"]); got != 65 {
+		t.Fatalf("total_amount = %v, want 65", got)
+	}
+
+	// 数额区间 + 按数额升序
+	_, resp = doReq(t, r, "GET", "/api/v1/provider/play-records?amount_min=40&sort=amount&order=asc", vtok, nil)
+	list := mustData(t, resp)["list"].([]any)
+	if len(list) != 2 || decFloat(list[0].(map[string]any)["amount"]) != 45 {
+		t.Fatalf("amount filter/sort = %v", list)
+	}
+
+	// 日期区间：今天包含全部，昨天之前为空
+	today := time.Now().Format("2006-01-02")
+	_, resp = doReq(t, r, "GET", "/api/v1/provider/play-records?start_from="+today+"&start_to="+today, vtok, nil)
+	if mustData(t, resp)["total"].(float64) != 3 {
+		t.Fatalf("date range total = %v, want 3", mustData(t, resp)["total"])
+	}
+	_, resp = doReq(t, r, "GET", "/api/v1/provider/play-records?start_to="+time.Now().AddDate(0, 0, -1).Format("2006-01-02"), vtok, nil)
+	if mustData(t, resp)["total"].(float64) != 0 {
+		t.Fatalf("past range total = %v, want 0", mustData(t, resp)["total"])
+	}
+
+	// CSV 导出沿用筛选
+	req := httptest.NewRequest("GET", "/api/v1/player/records/export?game=英雄联盟", nil)
+	req.Header.Set("Authorization", "Bearer "+ptok)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("export status/type = %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "英雄联盟") {
+		t.Fatalf("export lines = %q", lines)
+	}
+
+	// 已停用的游戏仍可筛选历史记录；未归入目录的旧记录（game_id = 0）按名称匹配
+	config.DB.Model(&models.Game{}).Where("name = ?", "英雄联盟").Update("is_active", false)
+	config.DB.Create(&models.PlayRecord{PlayerID: pid, ProviderID: vid, GameName: "英雄联盟", Status: models.PlayStatusCompleted, StartTime: time.Now()})
+	config.DB.Create(&models.PlayRecord{PlayerID: pid, ProviderID: vid, GameName: "炉石传说", Status: models.PlayStatusCompleted, StartTime: time.Now()})
+	_, resp = doReq(t, r, "GET", "/api/v1/player/records?game=英雄联盟", ptok, nil)
+	if d := mustData(t, resp); d["total"].(float64) != 2 {
+		t.Fatalf("inactive game filter total = %v, want 2", d["total"])
+	}
+	_, resp = doReq(t, r, "GET", "/api/v1/player/records?game=炉石传说", ptok, nil)
+	if d := mustData(t, resp); d["total"].(float64) != 1 {
+		t.Fatalf("legacy game filter total = %v, want 1", d["total"])
+	}
+}
+
+// --- 用户故事 3j：周期订阅预付排期，暂停 / 取消按未用场次退款 ---
+
+func TestSubscriptions(t *testing.T) {
+	r := newTestApp(t)
+	ptok, pid := register(t, r, "player", "player13", "阿离")
+	vtok, vid := register(t, r, "provider", "prov13", "星河")
+
+	doReq(t, r, "POST", "/api/v1/provider/balances", vtok, map[string]any{
+		"player_id": pid, "provider_id": vid, "type": "money", "amount": 100.00,
+	})
+	money := func() float64 {
+		_, resp := doReq(t, r, "GET", "/api/v1/player/dashboard", ptok, nil)
+		return decFloat(mustData(t, resp)["money_total"])
+	}
+	sessions := func(sub map[string]any, status string) []map[string]any {
+		var out []map[string]any
+		for _, s := range sub["sessions"].([]any) {
+			if rec := s.(map[string]any); rec["status"] == status {
+				out = append(out, rec)
+			}
+		}
+		return out
+	}
+
+	// 每天一场，4 场 × 10 → 预付 40，生成 4 场预约
+	_, resp := doReq(t, r, "POST", "/api/v1/player/subscriptions", ptok, map[string]any{
+		"provider_id": vid, "game_name": "王者荣耀", "weekdays": []int{0, 1, 2, 3, 4, 5, 6},
+		"time_of_day": "20:00", "price_per_session": 10, "total_sessions": 4,
+	})
+	sub := mustData(t, resp)
+	subID := uint(sub["id"].(float64))
+	if got := len(sessions(sub, "booked")); got != 4 {
+		t.Fatalf("booked sessions = %d, want 4", got)
+	}
+	if got := money(); got != 60 {
+		t.Fatalf("money after prepay = %v, want 60", got)
+	}
+
+	// 开始并完成第一场：不再扣费，按单价计入，已用 1 场
+	first := uint(sessions(sub, "booked")[0]["id"].(float64))
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/start", first), vtok, nil)
+	if mustData(t, resp)["status"] != "active" {
+		t.Fatalf("start failed: %v", resp)
+	}
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/complete", first), vtok, map[string]any{"duration": 60, "amount": 99})
+	if got := decFloat(mustData(t, resp)["charged_amount"]); got != 10 {
+		t.Fatalf("subscription session charged = %v, want 10", got)
+	}
+	if got := money(); got != 60 {
+		t.Fatalf("money after prepaid session = %v, want 60", got)
+	}
+
+	// 暂停：剩余 3 场预约取消并退 30
+	subURL := fmt.Sprintf("/api/v1/player/subscriptions/%d", subID)
+	_, resp = doReq(t, r, "PUT", subURL+"/pause", ptok, nil)
+	sub = mustData(t, resp)
+	if sub["status"] != "paused" || sub["used_sessions"].(float64) != 1 || len(sessions(sub, "booked")) != 0 {
+		t.Fatalf("pause result = %v", sub)
+	}
+	if got := money(); got != 90 {
+		t.Fatalf("money after pause = %v, want 90", got)
+	}
+
+	// 恢复：重新预付剩余 3 场并排期
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/subscriptions/%d/resume", subID), vtok, nil)
+	sub = mustData(t, resp)
+	if sub["status"] != "active" || len(sessions(sub, "booked")) != 3 {
+		t.Fatalf("resume result = %v", sub)
+	}
+	if got := money(); got != 60 {
+		t.Fatalf("money after resume = %v, want 60", got)
+	}
+
+	// 服务者取消单场预约：退该场 10，总场次减为 3
+	one := uint(sessions(sub, "booked")[0]["id"].(float64))
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/cancel", one), vtok, nil)
+	if got := money(); got != 70 {
+		t.Fatalf("money after single cancel = %v, want 70", got)
+	}
+
+	// 取消订阅：剩余 2 场退 20
+	_, resp = doReq(t, r, "PUT", subURL+"/cancel", ptok, nil)
+	sub = mustData(t, resp)
+	if sub["status"] != "cancelled" || sub["total_sessions"].(float64) != 3 || decFloat(sub["refunded_amount"]) != 60 {
+		t.Fatalf("cancel result = %v", sub)
+	}
+	if got := money(); got != 90 {
+		t.Fatalf("money after cancel = %v, want 90", got)
+	}
+	_, resp = doReq(t, r, "PUT", subURL+"/resume", ptok, nil)
+	if resp["code"].(float64) == 0 {
+		t.Fatal("resuming a cancelled subscription should fail")
+	}
+
+	// 暂停期间进行中的最后一场完成：恢复时场次已用完，订阅直接结束
+	_, resp = doReq(t, r, "POST", "/api/v1/player/subscriptions", ptok, map[string]any{
+		"provider_id": vid, "game_name": "王者荣耀", "weekdays": []int{0, 1, 2, 3, 4, 5, 6},
+		"time_of_day": "20:00", "price_per_session": 10, "total_sessions": 1,
+	})
+	sub = mustData(t, resp)
+	lastURL := fmt.Sprintf("/api/v1/player/subscriptions/%d", uint(sub["id"].(float64)))
+	last := uint(sessions(sub, "booked")[0]["id"].(float64))
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/start", last), vtok, nil)
+	if _, resp = doReq(t, r, "PUT", lastURL+"/pause", ptok, nil); mustData(t, resp)["status"] != "paused" {
+		t.Fatalf("pause with active session = %v", resp)
+	}
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/complete", last), vtok, map[string]any{"duration": 60})
+	_, resp = doReq(t, r, "PUT", lastURL+"/resume", ptok, nil)
+	if sub = mustData(t, resp); sub["status"] != "completed" || sub["used_sessions"].(float64) != 1 || len(sessions(sub, "booked")) != 0 {
+		t.Fatalf("resume after last session used = %v", sub)
+	}
+	if got := money(); got != 80 {
+		t.Fatalf("money after completed subscription = %v, want 80", got)
+	}
+}
+
+// --- 用户故事 3k：取消策略（免费窗口 / 迟取消费 / 爽约费），双方均可取消 ---
+
+func TestCancellationPolicies(t *testing.T) {
+	r := newTestApp(t)
+	ptok, pid := register(t, r, "player", "player14", "小满")
+	vtok, vid := register(t, r, "provider", "prov14", "青柠")
+
+	doReq(t, r, "POST", "/api/v1/provider/balances", vtok, map[string]any{
+		"player_id": pid, "provider_id": vid, "type": "money", "amount": 100.00,
+	})
+	money := func() float64 {
+		_, resp := doReq(t, r, "GET", "/api/v1/player/dashboard", ptok, nil)
+		return decFloat(mustData(t, resp)["money_total"])
+	}
+	start := func() uint {
+		_, resp := doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{"player_id": pid, "game_name": "王者荣耀"})
+		return uint(mustData(t, resp)["id"].(float64))
+	}
+
+	_, resp := doReq(t, r, "PUT", "/api/v1/provider/cancellation-policy", vtok, map[string]any{
+		"free_window_minutes": 60, "late_fee": 5, "no_show_fee": 8,
+	})
+	if resp["code"].(float64) != 0 {
+		t.Fatalf("save policy failed: %v", resp)
+	}
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/providers/%d/cancellation-policy", vid), "", nil)
+	if decFloat(mustData(t, resp)["late_fee"]) != 5 {
+		t.Fatalf("effective policy = %v", resp)
+	}
+
+	// 玩家取消进行中的一局：迟取消，扣 5
+	rec := start()
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/player/records/%d/cancel", rec), ptok, map[string]any{"reason": "临时有事"})
+	d := mustData(t, resp)
+	if d["status"] != "cancelled" || d["cancel_rule"] != "late" || decFloat(d["cancel_fee"]) != 5 ||
+		uint(d["cancelled_by"].(float64)) != pid || d["cancel_policy_id"] == nil ||
+		d["cancel_free_window_minutes"].(float64) != 60 || decFloat(d["cancel_no_show_fee"]) != 8 {
+		t.Fatalf("player late cancel = %v", d)
+	}
+	if got := money(); got != 95 {
+		t.Fatalf("money after late cancel = %v, want 95", got)
+	}
+
+	// 服务者登记爽约：扣 8；服务者普通取消：免费
+	rec = start()
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/cancel", rec), vtok, map[string]any{"no_show": true})
+	if d := mustData(t, resp); d["cancel_rule"] != "no_show" || decFloat(d["cancel_fee"]) != 8 {
+		t.Fatalf("no-show cancel = %v", d)
+	}
+	rec = start()
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/cancel", rec), vtok, nil)
+	if d := mustData(t, resp); d["cancel_rule"] != "provider" || decFloat(d["cancel_fee"]) != 0 {
+		t.Fatalf("provider cancel = %v", d)
+	}
+	if got := money(); got != 87 {
+		t.Fatalf("money after provider cancels = %v, want 87", got)
+	}
+
+	// 下周的订阅预约在免费窗口前由玩家取消：免费并退还该场预付
+	_, resp = doReq(t, r, "POST", "/api/v1/player/subscriptions", ptok, map[string]any{
+		"provider_id": vid, "game_name": "王者荣耀", "weekdays": []int{0, 1, 2, 3, 4, 5, 6},
+		"time_of_day": "20:00", "price_per_session": 10, "total_sessions": 2,
+		"start_date": time.Now().AddDate(0, 0, 7).Format("2006-01-02"),
+	})
+	booked := mustData(t, resp)["sessions"].([]any)[0].(map[string]any)
+	// 预约尚未开始，不能登记爽约
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/cancel", uint(booked["id"].(float64))), vtok, map[string]any{"no_show": true})
+	if resp["code"].(float64) == 0 {
+		t.Fatal("no-show before scheduled start should fail")
+	}
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/player/records/%d/cancel", uint(booked["id"].(float64))), ptok, nil)
+	if d := mustData(t, resp); d["cancel_rule"] != "free" || decFloat(d["cancel_fee"]) != 0 {
+		t.Fatalf("free cancel = %v", d)
+	}
+	if got := money(); got != 77 {
+		t.Fatalf("money after free subscription cancel = %v, want 77", got)
+	}
+
+	// 他人的记录不能取消
+	otok, _ := register(t, r, "player", "player15", "路人")
+	rec = start()
+	code, _ := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/player/records/%d/cancel", rec), otok, nil)
+	if code != http.StatusForbidden {
+		t.Fatalf("cancel by other player = %d, want 403", code)
+	}
+
+	// 余额不足以支付爽约费：按可用余额扣除，差额记为欠费，取消照常完成
+	_, p2id := register(t, r, "player", "player16", "阿离")
+	doReq(t, r, "POST", "/api/v1/provider/balances", vtok, map[string]any{
+		"player_id": p2id, "provider_id": vid, "type": "money", "amount": 3.00,
+	})
+	_, resp = doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{"player_id": p2id, "game_name": "王者荣耀"})
+	rec = uint(mustData(t, resp)["id"].(float64))
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/cancel", rec), vtok, map[string]any{"no_show": true})
+	d = mustData(t, resp)
+	if d["status"] != "cancelled" || decFloat(d["cancel_fee"]) != 8 || decFloat(d["cancel_fee_owed"]) != 5 {
+		t.Fatalf("no-show with insufficient balance = %v", d)
+	}
+	// 之后修改策略不影响已取消记录的快照
+	doReq(t, r, "PUT", "/api/v1/provider/cancellation-policy", vtok, map[string]any{"free_window_minutes": 0, "late_fee": 0, "no_show_fee": 0})
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/provider/play-records/%d", rec), vtok, nil)
+	if d := mustData(t, resp); decFloat(d["cancel_no_show_fee"]) != 8 || d["cancel_free_window_minutes"].(float64) != 60 {
+		t.Fatalf("cancel snapshot after policy change = %v", d)
+	}
+}
+
+// --- 用户故事 4：服务者申请加入工作室，工作室审批 ---
+
+func TestStudioApprovalFlow(t *testing.T) {
+	r := newTestApp(t)
+	vtok, _ := register(t, r, "provider", "prov3", "晚风")
+	stok, _ := register(t, r, "studio", "studio1", "星轨")
+
+	// 工作室创建
+	_, resp := doReq(t, r, "POST", "/api/v1/studio/", stok, map[string]any{"name": "星轨陪玩", "description": "FPS/MOBA"})
+	sid := uint(mustData(t, resp)["id"].(float64))
+
+	// 服务者申请
+	code, resp := doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", sid), vtok, map[string]any{"notes": "想加入"})
+	if code != http.StatusOK || resp["code"].(float64) != 0 {
+		t.Fatalf("apply failed: %d %v", code, resp)
+	}
+
+	// 工作室看到 1 条待审批
+	_, resp = doReq(t, r, "GET", "/api/v1/studio/dashboard", stok, nil)
+	if mustData(t, resp)["pending_count"].(float64) != 1 {
+		t.Fatalf("pending_count = %v, want 1", mustData(t, resp)["pending_count"])
+	}
+
+	// 取出申请并批准
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/studio/%d/applications", sid), stok, nil)
+	list := mustData(t, resp)["list"].([]any)
+	relID := uint(list[0].(map[string]any)["id"].(float64))
+	_, resp = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/applications/%d", relID), stok, map[string]any{"status": "approved"})
+	if resp["code"].(float64) != 0 {
+		t.Fatalf("approve failed: %v", resp)
+	}
+
+	// 成员列表出现该服务者
+	_, resp = doReq(t, r, "GET", "/api/v1/studio/members", stok, nil)
+	members := resp["data"].([]any)
+	if len(members) != 1 {
+		t.Fatalf("members = %d, want 1", len(members))
+	}
+
+	// 重复申请应失败
+	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", sid), vtok, map[string]any{"notes": "再申请"})
+	if resp["code"].(float64) == 0 {
+		t.Fatal("duplicate application should fail")
+	}
+}
+
+// --- 用户故事 4b：移除成员、主动退出与重新申请（同一条关联记录上流转） ---
+
+func TestStudioMembershipLifecycle(t *testing.T) {
+	r := newTestApp(t)
+	vtok, vid := register(t, r, "provider", "prov40", "晚风")
+	stok, _ := register(t, r, "studio", "studio40", "星轨")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/studio/", stok, map[string]any{"name": "星轨陪玩"})
+	sid := uint(mustData(t, resp)["id"].(float64))
+	applyURL := fmt.Sprintf("/api/v1/studio/%d/apply", sid)
+
+	apply := func(notes string) uint {
+		t.Helper()
+		_, resp := doReq(t, r, "POST", applyURL, vtok, map[string]any{"notes": notes})
+		d := mustData(t, resp)
+		if d["status"] != "pending" {
+			t.Fatalf("apply = %v", d)
+		}
+		return uint(d["id"].(float64))
+	}
+	process := func(relID uint, status string) {
+		t.Helper()
+		_, resp := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/applications/%d", relID), stok, map[string]any{"status": status, "notes": "审核意见"})
+		if mustData(t, resp)["status"] != status {
+			t.Fatalf("process %s = %v", status, resp)
+		}
+	}
+	memberCount := func() int {
+		_, resp := doReq(t, r, "GET", "/api/v1/studio/members", stok, nil)
+		return len(resp["data"].([]any))
+	}
+
+	// 被拒绝后可以重新申请，仍是同一条记录
+	relID := apply("第一次")
+	process(relID, "rejected")
+	if again := apply("第二次"); again != relID {
+		t.Fatalf("reapply created new relation %d, want %d", again, relID)
+	}
+	process(relID, "approved")
+	if memberCount() != 1 {
+		t.Fatal("approved provider should be a member")
+	}
+
+	// 工作室移除成员，记录原因
+	removeURL := fmt.Sprintf("/api/v1/studio/%d/members/%d", sid, vid)
+	_, resp = doReq(t, r, "DELETE", removeURL, stok, map[string]any{"reason": "长期不接单"})
+	if d := mustData(t, resp); d["status"] != "removed" || d["status_reason"] != "长期不接单" || d["status_changed_at"] == nil {
+		t.Fatalf("remove = %v", d)
+	}
+	if memberCount() != 0 {
+		t.Fatal("removed provider should not be a member")
+	}
+	if code, _ := doReq(t, r, "DELETE", removeURL, stok, nil); code != http.StatusNotFound {
+		t.Fatalf("remove non-member = %d, want 404", code)
+	}
+
+	// 被移除后重新申请、批准，再主动退出
+	apply("想回来")
+	process(relID, "approved")
+	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/leave", sid), vtok, nil)
+	if d := mustData(t, resp); d["status"] != "left" {
+		t.Fatalf("leave = %v", d)
+	}
+	if memberCount() != 0 {
+		t.Fatal("left provider should not be a member")
+	}
+	if _, resp := doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/leave", sid), vtok, nil); resp["code"].(float64) == 0 {
+		t.Fatal("leaving twice should fail")
+	}
+
+	// 工作室收到重新申请与退出通知
+	_, resp = doReq(t, r, "GET", "/api/v1/notifications", stok, nil)
+	if n := int(mustData(t, resp)["total"].(float64)); n != 3 {
+		t.Fatalf("studio notifications = %d, want 3", n)
+	}
+
+	_, resp = doReq(t, r, "GET", "/api/v1/provider/relations", vtok, nil)
+	if rels := resp["data"].([]any); len(rels) != 1 || rels[0].(map[string]any)["status"] != "left" {
+		t.Fatalf("relations = %v", rels)
+	}
+}
+
+// --- 用户故事 4c：关联状态流转历史，双方均可查看 ---
+
+func TestRelationStatusHistory(t *testing.T) {
+	r := newTestApp(t)
+	vtok, vid := register(t, r, "provider", "prov41", "晚风")
+	stok, studioUserID := register(t, r, "studio", "studio41", "星轨")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/studio/", stok, map[string]any{"name": "星轨陪玩"})
+	sid := uint(mustData(t, resp)["id"].(float64))
+
+	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", sid), vtok, map[string]any{"notes": "擅长 FPS"})
+	relID := uint(mustData(t, resp)["id"].(float64))
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/applications/%d", relID), stok, map[string]any{"status": "rejected", "notes": "请补充段位截图"})
+	doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", sid), vtok, map[string]any{"notes": "已补充截图"})
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/applications/%d", relID), stok, map[string]any{"status": "approved", "notes": "欢迎"})
+
+	want := []struct {
+		from, to, note string
+		actor          uint
+	}{
+		{"", "pending", "擅长 FPS", vid},
+		{"pending", "rejected", "请补充段位截图", studioUserID},
+		{"rejected", "pending", "已补充截图", vid},
+		{"pending", "approved", "欢迎", studioUserID},
+	}
+	checkHistory := func(who string, rel map[string]any) {
+		t.Helper()
+		history := rel["history"].([]any)
+		if len(history) != len(want) {
+			t.Fatalf("%s history = %v", who, history)
+		}
+		for i, w := range want {
+			h := history[i].(map[string]any)
+			if h["from_status"] != w.from || h["to_status"] != w.to || h["note"] != w.note || uint(h["actor_id"].(float64)) != w.actor {
+				t.Fatalf("%s history[%d] = %v, want %+v", who, i, h, w)
+			}
+		}
+	}
+
+	_, resp = doReq(t, r, "GET", "/api/v1/provider/relations", vtok, nil)
+	checkHistory("provider", resp["data"].([]any)[0].(map[string]any))
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/studio/%d/applications", sid), stok, nil)
+	checkHistory("studio", mustData(t, resp)["list"].([]any)[0].(map[string]any))
+}
+
+// --- 用户故事 4d：工作室邀请码（一次性 / 过期 / 定向），兑换后自动加入 ---
+
+func TestStudioInvites(t *testing.T) {
+	r := newTestApp(t)
+	stok, _ := register(t, r, "studio", "studio42", "星轨")
+	v1, v1id := register(t, r, "provider", "prov42", "晚风")
+	v2, _ := register(t, r, "provider", "prov43", "星河")
+	v3, _ := register(t, r, "provider", "prov44", "北辰")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/studio/", stok, map[string]any{"name": "星轨陪玩"})
+	sid := uint(mustData(t, resp)["id"].(float64))
+	invitesURL := fmt.Sprintf("/api/v1/studio/%d/invites", sid)
+
+	newInvite := func(body map[string]any) (uint, string) {
+		t.Helper()
+		_, resp := doReq(t, r, "POST", invitesURL, stok, body)
+		d := mustData(t, resp)
+		return uint(d["id"].(float64)), d["code"].(string)
+	}
+	redeem := func(tok, code string) map[string]any {
+		_, resp := doReq(t, r, "POST", "/api/v1/provider/invites/redeem", tok, map[string]any{"code": code})
+		return resp
+	}
+
+	// 一次性邀请：第一人兑换后直接成为成员，第二人失败
+	singleID, single := newInvite(map[string]any{"max_uses": 1})
+	d := mustData(t, redeem(v1, strings.ToLower(single)))
+	if d["status"] != "approved" || uint(d["invite_id"].(float64)) != singleID {
+		t.Fatalf("redeem = %v", d)
+	}
+	if resp := redeem(v2, single); resp["code"].(float64) == 0 {
+		t.Fatal("single-use invite redeemed twice")
+	}
+	if resp := redeem(v1, "NOPE"); resp["code"].(float64) == 0 {
+		t.Fatal("unknown code should fail")
+	}
+
+	// 定向邀请只能由指定用户名兑换；已有被拒绝的申请也可通过邀请加入
+	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", sid), v3, map[string]any{"notes": "申请"})
+	relID := uint(mustData(t, resp)["id"].(float64))
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/applications/%d", relID), stok, map[string]any{"status": "rejected"})
+	_, targeted := newInvite(map[string]any{"username": "prov44"})
+	if resp := redeem(v2, targeted); resp["code"].(float64) == 0 {
+		t.Fatal("targeted invite redeemed by another user")
+	}
+	if d := mustData(t, redeem(v3, targeted)); d["status"] != "approved" || uint(d["id"].(float64)) != relID {
+		t.Fatalf("targeted redeem = %v", d)
+	}
+
+	// 过期与撤销
+	expiredID, expired := newInvite(map[string]any{"expires_in_hours": 1})
+	config.DB.Model(&models.StudioInvite{}).Where("id = ?", expiredID).Update("expires_at", time.Now().Add(-time.Minute))
+	if resp := redeem(v2, expired); resp["code"].(float64) == 0 {
+		t.Fatal("expired invite should fail")
+	}
+	openID, open := newInvite(map[string]any{})
+	doReq(t, r, "DELETE", fmt.Sprintf("%s/%d", invitesURL, openID), stok, nil)
+	if resp := redeem(v2, open); resp["code"].(float64) == 0 {
+		t.Fatal("revoked invite should fail")
+	}
+
+	// 被移除的成员不能凭公开邀请码重新加入，定向邀请可以
+	doReq(t, r, "DELETE", fmt.Sprintf("/api/v1/studio/%d/members/%d", sid, v1id), stok, map[string]any{"reason": "违规"})
+	_, shared := newInvite(map[string]any{})
+	if resp := redeem(v1, shared); resp["code"].(float64) == 0 {
+		t.Fatal("removed member rejoined through a shared invite")
+	}
+	// 申请被拒绝的服务者同样不能凭公开邀请码加入
+	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", sid), v2, map[string]any{"notes": "申请"})
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/applications/%d", uint(mustData(t, resp)["id"].(float64))), stok, map[string]any{"status": "rejected"})
+	if resp := redeem(v2, shared); resp["code"].(float64) == 0 {
+		t.Fatal("rejected applicant joined through a shared invite")
+	}
+	_, back := newInvite(map[string]any{"username": "prov42"})
+	if d := mustData(t, redeem(v1, back)); d["status"] != "approved" {
+		t.Fatalf("targeted redeem after removal = %v", d)
+	}
+
+	_, resp = doReq(t, r, "GET", "/api/v1/studio/members", stok, nil)
+	if n := len(resp["data"].([]any)); n != 2 {
+		t.Fatalf("members = %d, want 2", n)
+	}
+	_, resp = doReq(t, r, "GET", invitesURL, stok, nil)
+	for _, item := range resp["data"].([]any) {
+		inv := item.(map[string]any)
+		if uint(inv["id"].(float64)) == singleID && inv["used_count"].(float64) != 1 {
+			t.Fatalf("single-use invite = %v", inv)
+		}
+	}
+}
+
+// --- 用户故事 4e：工作室员工角色（经理 / 财务 / 审核员）按权限操作 ---
+
+func TestStudioStaffRoles(t *testing.T) {
+	r := newTestApp(t)
+	otok, _ := register(t, r, "studio", "studio45", "星轨")
+	ftok, fid := register(t, r, "studio", "finance45", "账房")
+	rtok, _ := register(t, r, "studio", "reviewer45", "审核")
+	mtok, _ := register(t, r, "studio", "manager45", "经理")
+	vtok, vid := register(t, r, "provider", "prov45", "晚风")
+	_, pid := register(t, r, "player", "player45", "小柚")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/studio/", otok, map[string]any{"name": "星轨陪玩"})
+	sid := uint(mustData(t, resp)["id"].(float64))
+	staffURL := fmt.Sprintf("/api/v1/studio/%d/staff", sid)
+	for uname, role := range map[string]string{"finance45": "finance", "reviewer45": "reviewer", "manager45": "manager"} {
+		if _, resp := doReq(t, r, "POST", staffURL, otok, map[string]any{"username": uname, "role": role}); resp["code"].(float64) != 0 {
+			t.Fatalf("add %s: %v", uname, resp)
+		}
+	}
+	if _, resp := doReq(t, r, "POST", staffURL, otok, map[string]any{"username": "prov45", "role": "manager"}); resp["code"].(float64) == 0 {
+		t.Fatal("provider account should not become staff")
+	}
+	if code, _ := doReq(t, r, "POST", staffURL, mtok, map[string]any{"username": "finance45", "role": "manager"}); code != http.StatusForbidden {
+		t.Fatalf("manager managing staff = %d, want 403", code)
+	}
+
+	// 审核员处理申请；财务不能处理申请
+	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", sid), vtok, map[string]any{"notes": "想加入"})
+	relID := uint(mustData(t, resp)["id"].(float64))
+	processURL := fmt.Sprintf("/api/v1/studio/applications/%d", relID)
+	if code, _ := doReq(t, r, "PUT", processURL, ftok, map[string]any{"status": "approved"}); code != http.StatusForbidden {
+		t.Fatalf("finance processing application = %d, want 403", code)
+	}
+	if _, resp := doReq(t, r, "PUT", processURL, rtok, map[string]any{"status": "approved"}); mustData(t, resp)["status"] != "approved" {
+		t.Fatalf("reviewer approve = %v", resp)
+	}
+
+	// 财务可为成员的玩家充值；审核员不能
+	recharge := map[string]any{"player_id": pid, "provider_id": vid, "type": "money", "amount": 100}
+	if code, _ := doReq(t, r, "POST", "/api/v1/studio/balances", rtok, recharge); code == http.StatusOK {
+		t.Fatal("reviewer should not recharge")
+	}
+	if _, resp := doReq(t, r, "POST", "/api/v1/studio/balances", ftok, recharge); resp["code"].(float64) != 0 || uint(mustData(t, resp)["studio_id"].(float64)) != sid {
+		t.Fatalf("finance recharge = %v", resp)
+	}
+
+	// 编辑工作室：经理可以，财务不可以；所有员工都能看控制台与成员
+	edit := map[string]any{"name": "星轨陪玩 Pro"}
+	if code, _ := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/%d", sid), ftok, edit); code != http.StatusForbidden {
+		t.Fatalf("finance edit = %d, want 403", code)
+	}
+	if _, resp := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/%d", sid), mtok, edit); resp["code"].(float64) != 0 {
+		t.Fatalf("manager edit = %v", resp)
+	}
+	for _, tok := range []string{ftok, rtok, mtok} {
+		_, resp := doReq(t, r, "GET", "/api/v1/studio/members", tok, nil)
+		if len(resp["data"].([]any)) != 1 {
+			t.Fatalf("staff members view = %v", resp)
+		}
+	}
+
+	// 降级与移除即时生效（权限缓存失效）
+	doReq(t, r, "PUT", fmt.Sprintf("%s/%d", staffURL, fid), otok, map[string]any{"role": "reviewer"})
+	if code, _ := doReq(t, r, "POST", "/api/v1/studio/balances", ftok, recharge); code == http.StatusOK {
+		t.Fatal("demoted finance should not recharge")
+	}
+	doReq(t, r, "DELETE", fmt.Sprintf("%s/%d", staffURL, fid), otok, nil)
+	if code, _ := doReq(t, r, "GET", fmt.Sprintf("/api/v1/studio/%d/applications", sid), ftok, nil); code != http.StatusForbidden {
+		t.Fatalf("removed staff listing applications = %d, want 403", code)
+	}
+	_, resp = doReq(t, r, "GET", staffURL, otok, nil)
+	if n := len(mustData(t, resp)["staff"].([]any)); n != 2 {
+		t.Fatalf("staff = %d, want 2", n)
+	}
+}
+
+// --- 用户故事 4f：一个账号经营多个工作室（路由或 X-Studio-ID 选择）与汇总控制台 ---
+
+func TestMultipleStudios(t *testing.T) {
+	r := newTestApp(t)
+	otok, _ := register(t, r, "studio", "studio46", "星轨")
+	xtok, _ := register(t, r, "studio", "studio47", "别家")
+	vtok, vid := register(t, r, "provider", "prov46", "晚风")
+	_, pid := register(t, r, "player", "player46", "小柚")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/studio/", otok, map[string]any{"name": "星轨 FPS"})
+	first := uint(mustData(t, resp)["id"].(float64))
+	_, resp = doReq(t, r, "POST", "/api/v1/studio/", otok, map[string]any{"name": "星轨 MOBA"})
+	second := uint(mustData(t, resp)["id"].(float64))
+
+	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", second), vtok, map[string]any{"notes": "想加入"})
+	relID := uint(mustData(t, resp)["id"].(float64))
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/applications/%d", relID), otok, map[string]any{"status": "approved"})
+
+	withStudio := func(tok, path string, studioID uint) (int, map[string]any) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+tok)
+		req.Header.Set(controllers.StudioHeader, fmt.Sprint(studioID))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var out map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+
+	// 默认作用于第一个工作室；第二个工作室通过路由或请求头选择
+	_, resp = doReq(t, r, "GET", "/api/v1/studio/members", otok, nil)
+	if n := len(resp["data"].([]any)); n != 0 {
+		t.Fatalf("default studio members = %d, want 0", n)
+	}
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/studio/%d/members", second), otok, nil)
+	if n := len(resp["data"].([]any)); n != 1 {
+		t.Fatalf("second studio members = %d, want 1", n)
+	}
+	_, resp = withStudio(otok, "/api/v1/studio/dashboard", second)
+	if d := mustData(t, resp); d["member_count"].(float64) != 1 || uint(d["studio"].(map[string]any)["id"].(float64)) != second {
+		t.Fatalf("header dashboard = %v", d)
+	}
+	if code, _ := withStudio(xtok, "/api/v1/studio/dashboard", second); code != http.StatusForbidden {
+		t.Fatalf("other account selecting studio = %d, want 403", code)
+	}
+
+	// 余额操作按 studio_id 选择工作室
+	recharge := map[string]any{"player_id": pid, "provider_id": vid, "studio_id": second, "type": "money", "amount": 50}
+	if _, resp := doReq(t, r, "POST", "/api/v1/studio/balances", otok, recharge); uint(mustData(t, resp)["studio_id"].(float64)) != second {
+		t.Fatalf("recharge = %v", resp)
+	}
+	recharge["studio_id"] = first
+	if _, resp := doReq(t, r, "POST", "/api/v1/studio/balances", otok, recharge); resp["code"].(float64) == 0 {
+		t.Fatal("provider is not a member of the first studio")
+	}
+
+	_, resp = doReq(t, r, "GET", "/api/v1/studio/mine", otok, nil)
+	if mine := resp["data"].([]any); len(mine) != 2 || mine[0].(map[string]any)["role"] != "owner" {
+		t.Fatalf("mine = %v", mine)
+	}
+	_, resp = doReq(t, r, "GET", "/api/v1/studio/dashboard/overview", otok, nil)
+	d := mustData(t, resp)
+	totals := d["totals"].(map[string]any)
+	if len(d["studios"].([]any)) != 2 || totals["member_count"].(float64) != 1 || totals["served_player_count"].(float64) != 1 || decFloat(totals["monthly_flow"]) != 50 {
+		t.Fatalf("overview = %v", d)
+	}
+}
+
+// --- 用户故事 4g：工作室所有权两步转让（发起 → 接收），员工权限随之转移 ---
+
+func TestStudioOwnershipTransfer(t *testing.T) {
+	r := newTestApp(t)
+	otok, oid := register(t, r, "studio", "studio48", "老板")
+	ntok, nid := register(t, r, "studio", "studio49", "新老板")
+	ftok, _ := register(t, r, "studio", "finance48", "账房")
+	_, _ = register(t, r, "provider", "prov48", "晚风")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/studio/", otok, map[string]any{"name": "星轨陪玩"})
+	sid := uint(mustData(t, resp)["id"].(float64))
+	staffURL := fmt.Sprintf("/api/v1/studio/%d/staff", sid)
+	doReq(t, r, "POST", staffURL, otok, map[string]any{"username": "studio49", "role": "reviewer"})
+	doReq(t, r, "POST", staffURL, otok, map[string]any{"username": "finance48", "role": "finance"})
+	transferURL := fmt.Sprintf("/api/v1/studio/%d/transfer", sid)
+
+	if _, resp := doReq(t, r, "POST", transferURL, otok, map[string]any{"username": "prov48"}); resp["code"].(float64) == 0 {
+		t.Fatal("transfer to provider account should fail")
+	}
+	if code, _ := doReq(t, r, "POST", transferURL, ftok, map[string]any{"username": "studio49"}); code != http.StatusForbidden {
+		t.Fatalf("staff initiating transfer = %d, want 403", code)
+	}
+
+	// 发起后可撤回；再次发起并由接收方确认
+	doReq(t, r, "POST", transferURL, otok, map[string]any{"username": "studio49"})
+	if _, resp := doReq(t, r, "POST", transferURL, otok, map[string]any{"username": "studio49"}); resp["code"].(float64) == 0 {
+		t.Fatal("second pending transfer should fail")
+	}
+	doReq(t, r, "DELETE", transferURL, otok, nil)
+	_, resp = doReq(t, r, "POST", transferURL, otok, map[string]any{"username": "studio49", "previous_owner_role": "manager"})
+	transferID := uint(mustData(t, resp)["id"].(float64))
+
+	// 确认前：接收方仍只是审核员，不能编辑工作室（此时权限已被缓存）
+	edit := map[string]any{"name": "星轨陪玩 2.0"}
+	if code, _ := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/%d", sid), ntok, edit); code != http.StatusForbidden {
+		t.Fatalf("reviewer edit before transfer = %d, want 403", code)
+	}
+	if code, _ := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/transfers/%d/accept", transferID), ftok, nil); code != http.StatusNotFound {
+		t.Fatalf("accept by non-target = %d, want 404", code)
+	}
+	_, resp = doReq(t, r, "GET", "/api/v1/studio/transfers/incoming", ntok, nil)
+	if len(resp["data"].([]any)) != 1 {
+		t.Fatalf("incoming = %v", resp)
+	}
+	if _, resp := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/transfers/%d/accept", transferID), ntok, nil); mustData(t, resp)["status"] != "accepted" {
+		t.Fatalf("accept = %v", resp)
+	}
+
+	// 新所有者拥有全部权限；原所有者留任经理，不能再管理员工；财务保持原权限
+	if _, resp := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/%d", sid), ntok, edit); resp["code"].(float64) != 0 {
+		t.Fatalf("new owner edit = %v", resp)
+	}
+	_, resp = doReq(t, r, "GET", staffURL, ntok, nil)
+	d := mustData(t, resp)
+	if uint(d["owner_id"].(float64)) != nid {
+		t.Fatalf("owner = %v, want %d", d["owner_id"], nid)
+	}
+	roles := map[uint]string{}
+	for _, item := range d["staff"].([]any) {
+		st := item.(map[string]any)
+		roles[uint(st["user_id"].(float64))] = st["role"].(string)
+	}
+	if len(roles) != 2 || roles[oid] != "manager" {
+		t.Fatalf("staff after transfer = %v", roles)
+	}
+	if code, _ := doReq(t, r, "POST", staffURL, otok, map[string]any{"username": "prov48", "role": "finance"}); code != http.StatusForbidden {
+		t.Fatalf("previous owner managing staff = %d, want 403", code)
+	}
+	if _, resp := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/transfers/%d/accept", transferID), ntok, nil); resp["code"].(float64) == 0 {
+		t.Fatal("accepting twice should fail")
+	}
+
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/studio/%d/transfers", sid), ftok, nil)
+	history := resp["data"].([]any)
+	if len(history) != 2 || history[0].(map[string]any)["status"] != "accepted" || history[1].(map[string]any)["status"] != "cancelled" {
+		t.Fatalf("transfer history = %v", history)
+	}
+}
+
+// --- 用户故事 4h：工作室停用 / 重新启用，名下余额按策略冻结、转移或退还 ---
+
+func TestStudioDeactivation(t *testing.T) {
+	r := newTestApp(t)
+	otok, _ := register(t, r, "studio", "studio50", "星轨")
+	vtok, vid := register(t, r, "provider", "prov50", "晚风")
+	v2tok, _ := register(t, r, "provider", "prov51", "星河")
+	ptok, pid := register(t, r, "player", "player50", "小柚")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/studio/", otok, map[string]any{"name": "星轨陪玩"})
+	sid := uint(mustData(t, resp)["id"].(float64))
+	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", sid), vtok, map[string]any{"notes": "想加入"})
+	relID := uint(mustData(t, resp)["id"].(float64))
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/studio/applications/%d", relID), otok, map[string]any{"status": "approved"})
+
+	recharge := map[string]any{"player_id": pid, "provider_id": vid, "studio_id": sid, "type": "money", "amount": 80}
+	doReq(t, r, "POST", "/api/v1/studio/balances", otok, recharge)
+	deactivateURL := fmt.Sprintf("/api/v1/studio/%d/deactivate", sid)
+	reactivateURL := fmt.Sprintf("/api/v1/studio/%d/reactivate", sid)
+
+	// 有进行中的陪玩时不能停用
+	_, resp = doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{
+		"player_id": pid, "studio_id": sid, "game_name": "王者荣耀", "settle_type": "money",
+	})
+	recID := uint(mustData(t, resp)["id"].(float64))
+	if _, resp := doReq(t, r, "PUT", deactivateURL, otok, map[string]any{"balance_policy": "block"}); resp["code"].(float64) == 0 {
+		t.Fatal("deactivation with active play record should fail")
+	}
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/cancel", recID), vtok, nil)
+
+	// 确认窗口内的已完成记录同样阻止停用；若工作室已停用，申诉返回 400 而非 500
+	p2tok, p2id := register(t, r, "player", "player51", "阿离")
+	doReq(t, r, "POST", "/api/v1/studio/balances", otok, map[string]any{
+		"player_id": p2id, "provider_id": vid, "studio_id": sid, "type": "money", "amount": 10,
+	})
+	_, resp = doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{
+		"player_id": p2id, "studio_id": sid, "game_name": "王者荣耀", "settle_type": "money",
+	})
+	pendingID := uint(mustData(t, resp)["id"].(float64))
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/complete", pendingID), vtok, map[string]any{
+		"duration": 30, "amount": 10, "settle": true,
+	})
+	if _, resp := doReq(t, r, "PUT", deactivateURL, otok, map[string]any{"balance_policy": "block"}); resp["code"].(float64) == 0 {
+		t.Fatal("deactivation with record pending confirmation should fail")
+	}
+	config.DB.Model(&models.Studio{}).Where("id = ?", sid).Update("is_active", false)
+	if code, _ := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/player/records/%d/dispute", pendingID), p2tok, map[string]any{"reason": "未打满"}); code != http.StatusBadRequest {
+		t.Fatalf("dispute under inactive studio = %d, want 400", code)
+	}
+	config.DB.Model(&models.Studio{}).Where("id = ?", sid).Update("is_active", true)
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/player/records/%d/confirm", pendingID), p2tok, nil)
+
+	// 停用前提交、尚未处理的申请
+	v3tok, _ := register(t, r, "provider", "prov52", "青柠")
+	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", sid), v3tok, map[string]any{"notes": "想加入"})
+	pendingRelID := uint(mustData(t, resp)["id"].(float64))
+
+	// block：余额保留但不可变动，不接受申请，列表隐藏，历史仍可查
+	if _, resp := doReq(t, r, "PUT", deactivateURL, otok, map[string]any{"balance_policy": "block", "reason": "装修"}); resp["code"].(float64) != 0 {
+		t.Fatalf("deactivate = %v", resp)
+	}
+	processURL := fmt.Sprintf("/api/v1/studio/applications/%d", pendingRelID)
+	if _, resp := doReq(t, r, "PUT", processURL, otok, map[string]any{"status": "approved"}); resp["code"].(float64) == 0 {
+		t.Fatal("approving an application of an inactive studio should fail")
+	}
+	if _, resp := doReq(t, r, "PUT", processURL, otok, map[string]any{"status": "rejected"}); resp["code"].(float64) != 0 {
+		t.Fatalf("rejecting under inactive studio = %v", resp)
+	}
+	if _, resp := doReq(t, r, "POST", "/api/v1/studio/balances", otok, recharge); resp["code"].(float64) == 0 {
+		t.Fatal("recharge under inactive studio should fail")
+	}
+	if _, resp := doReq(t, r, "POST", fmt.Sprintf("/api/v1/studio/%d/apply", sid), v2tok, map[string]any{"notes": "想加入"}); resp["code"].(float64) == 0 {
+		t.Fatal("apply to inactive studio should fail")
+	}
+	if _, resp := doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{
+		"player_id": pid, "studio_id": sid, "game_name": "王者荣耀",
+	}); resp["code"].(float64) == 0 {
+		t.Fatal("play record under inactive studio should fail")
+	}
+	_, resp = doReq(t, r, "GET", "/api/v1/studios", "", nil)
+	if n := int(mustData(t, resp)["total"].(float64)); n != 0 {
+		t.Fatalf("inactive studio listed: %d", n)
+	}
+	balanceOf := func(studioID uint) float64 {
+		t.Helper()
+		_, resp := doReq(t, r, "GET", "/api/v1/player/balances", ptok, nil)
+		for _, item := range mustData(t, resp)["list"].([]any) {
+			b := item.(map[string]any)
+			if uint(b["studio_id"].(float64)) == studioID {
+				return decFloat(b["amount"])
+			}
+		}
+		return -1
+	}
+	if got := balanceOf(sid); got != 80 {
+		t.Fatalf("blocked balance = %v, want 80", got)
+	}
+
+	// 重新启用后恢复，再按 transfer 停用：余额转入服务者个人余额
+	if _, resp := doReq(t, r, "PUT", reactivateURL, otok, nil); mustData(t, resp)["is_active"] != true {
+		t.Fatalf("reactivate = %v", resp)
+	}
+	if _, resp := doReq(t, r, "POST", "/api/v1/studio/balances", otok, recharge); resp["code"].(float64) != 0 {
+		t.Fatalf("recharge after reactivation = %v", resp)
+	}
+	_, resp = doReq(t, r, "PUT", deactivateURL, otok, map[string]any{"balance_policy": "transfer"})
+	if d := mustData(t, resp); d["settled_balances"].(float64) != 1 {
+		t.Fatalf("transfer deactivate = %v", d)
+	}
+	if studioBal, personal := balanceOf(sid), balanceOf(0); studioBal != 0 || personal != 160 {
+		t.Fatalf("after transfer studio=%v personal=%v, want 0/160", studioBal, personal)
+	}
+	_, resp = doReq(t, r, "GET", "/api/v1/notifications", ptok, nil)
+	if n := int(mustData(t, resp)["total"].(float64)); n != 1 {
+		t.Fatalf("player notifications = %d, want 1", n)
+	}
+}
+
+// --- 用户故事 4i：工作室申请策略（条款 / 必填资料 / 自动批准与拒绝） ---
+
+func TestStudioApplicationPolicy(t *testing.T) {
+	r := newTestApp(t)
+	otok, _ := register(t, r, "studio", "studio60", "星轨")
+	newbieTok, _ := register(t, r, "provider", "prov60", "晚风")
+	vetTok, _ := register(t, r, "provider", "prov61", "星河")
+	_, pid := register(t, r, "player", "player60", "小柚")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/studio/", otok, map[string]any{"name": "星轨陪玩"})
+	sid := uint(mustData(t, resp)["id"].(float64))
+	policyURL := fmt.Sprintf("/api/v1/studio/%d/application-policy", sid)
+	applyURL := fmt.Sprintf("/api/v1/studio/%d/apply", sid)
+
+	// 老手先完成一局
+	_, resp = doReq(t, r, "POST", "/api/v1/provider/play-records", vetTok, map[string]any{"player_id": pid, "game_name": "王者荣耀"})
+	recID := uint(mustData(t, resp)["id"].(float64))
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/complete", recID), vetTok, map[string]any{"duration": 30})
+
+	if _, resp := doReq(t, r, "PUT", policyURL, otok, map[string]any{
+		"terms": "接单须遵守平台规范", "required_fields": []string{"段位", "擅长位置"},
+		"min_completed_sessions": 1, "auto_approve": true, "auto_reject": true,
+	}); resp["code"].(float64) != 0 {
+		t.Fatalf("upsert policy = %v", resp)
+	}
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/studios/%d/application-policy", sid), "", nil)
+	if got := mustData(t, resp)["required_fields"].([]any); len(got) != 2 {
+		t.Fatalf("public policy required_fields = %v", got)
+	}
+
+	// 未同意条款 / 缺少必填资料
+	fields := map[string]string{"段位": "王者", "擅长位置": "打野"}
+	if _, resp := doReq(t, r, "POST", applyURL, newbieTok, map[string]any{"fields": fields}); resp["code"].(float64) == 0 {
+		t.Fatal("apply without accepting terms should fail")
+	}
+	if _, resp := doReq(t, r, "POST", applyURL, newbieTok, map[string]any{
+		"accept_terms": true, "fields": map[string]string{"段位": "王者"},
+	}); resp["code"].(float64) == 0 {
+		t.Fatal("apply without required fields should fail")
+	}
+
+	// 未完成过陪玩：自动拒绝，系统（actor 0）记入历史
+	_, resp = doReq(t, r, "POST", applyURL, newbieTok, map[string]any{"accept_terms": true, "fields": fields})
+	rel := mustData(t, resp)
+	if rel["status"] != "rejected" || rel["rule_fired"] != "auto_reject" || rel["terms_accepted_at"] == nil {
+		t.Fatalf("newbie application = %v", rel)
+	}
+	_, resp = doReq(t, r, "GET", "/api/v1/provider/relations", newbieTok, nil)
+	history := resp["data"].([]any)[0].(map[string]any)["history"].([]any)
+	if last := history[len(history)-1].(map[string]any); last["to_status"] != "rejected" || last["actor_id"].(float64) != 0 {
+		t.Fatalf("auto reject history = %v", last)
+	}
+
+	// 满足门槛：自动批准，资料随申请保存
+	_, resp = doReq(t, r, "POST", applyURL, vetTok, map[string]any{"accept_terms": true, "fields": fields})
+	rel = mustData(t, resp)
+	if rel["status"] != "approved" || rel["rule_fired"] != "auto_approve" || rel["application_fields"].(map[string]any)["段位"] != "王者" {
+		t.Fatalf("veteran application = %v", rel)
+	}
+
+	// 关闭自动拒绝后未达标者转人工审核，保持待处理
+	doReq(t, r, "PUT", policyURL, otok, map[string]any{"min_completed_sessions": 1, "auto_approve": true})
+	_, resp = doReq(t, r, "POST", applyURL, newbieTok, map[string]any{"notes": "再试一次"})
+	if rel := mustData(t, resp); rel["status"] != "pending" || rel["rule_fired"] != "manual_review" {
+		t.Fatalf("manual review application = %v", rel)
+	}
+}
+
+// --- 用户故事 5：玩家评价服务者，评分汇总 ---
+
+func TestReviewFlow(t *testing.T) {
+	r := newTestApp(t)
+	ptok, _ := register(t, r, "player", "player4", "小柚")
+	_, vid := register(t, r, "provider", "prov4", "晚风")
+
+	for _, rating := range []int{5, 4} {
+		_, resp := doReq(t, r, "POST", "/api/v1/player/reviews", ptok, map[string]any{
+			"target_type": "provider", "target_id": vid, "rating": rating, "content": "不错",
+		})
+		if resp["code"].(float64) != 0 {
+			t.Fatalf("create review failed: %v", resp)
+		}
+	}
+
+	_, resp := doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews/summary?target_type=provider&target_id=%d", vid), "", nil)
+	d := mustData(t, resp)
+	if d["count"].(float64) != 2 {
+		t.Fatalf("review count = %v, want 2", d["count"])
+	}
+	if avg := d["average_rating"].(float64); avg < 4.4 || avg > 4.6 {
+		t.Fatalf("average_rating = %v, want ~4.5", avg)
+	}
+}
+
+// --- 用户故事 5b：评价关联游玩记录（每局一条，已验证标记） ---
+
+func TestVerifiedReviews(t *testing.T) {
+	r := newTestApp(t)
+	ptok, pid := register(t, r, "player", "player16", "小柚")
+	otok, _ := register(t, r, "player", "player17", "阿离")
+	vtok, vid := register(t, r, "provider", "prov16", "晚风")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/provider/play-records", vtok, map[string]any{"player_id": pid, "game_name": "王者荣耀"})
+	recID := uint(mustData(t, resp)["id"].(float64))
+	review := map[string]any{"target_type": "provider", "target_id": vid, "rating": 5, "play_record_id": recID}
+
+	// 进行中的记录不能评价
+	_, resp = doReq(t, r, "POST", "/api/v1/player/reviews", ptok, review)
+	if resp["code"].(float64) == 0 {
+		t.Fatal("reviewing an active record should fail")
+	}
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/provider/play-records/%d/complete", recID), vtok, map[string]any{"settle": false})
+
+	// 他人的记录不能评价
+	code, _ := doReq(t, r, "POST", "/api/v1/player/reviews", otok, review)
+	if code != http.StatusForbidden {
+		t.Fatalf("review other's record = %d, want 403", code)
+	}
+
+	_, resp = doReq(t, r, "POST", "/api/v1/player/reviews", ptok, review)
+	if d := mustData(t, resp); d["verified"] != true {
+		t.Fatalf("review with record should be verified: %v", d)
+	}
+	_, resp = doReq(t, r, "POST", "/api/v1/player/reviews", ptok, review)
+	if resp["code"].(float64) == 0 {
+		t.Fatal("second review on the same record should fail")
+	}
+	// 绕过应用层校验（模拟并发提交）时由唯一索引拒绝
+	dup := models.Review{PlayerID: pid, TargetType: models.ReviewTargetProvider, TargetID: vid, Rating: 1, PlayRecordID: &recID}
+	if err := config.DB.Create(&dup).Error; err == nil {
+		t.Fatal("unique index should reject a second review on the same record")
+	}
+
+	// 旧库中同一局的重复评价：迁移时保留最早一条，其余解除关联后才能建立唯一索引
+	if err := config.DB.Migrator().DropIndex(&models.Review{}, "uniq_review_play_record"); err != nil {
+		t.Fatalf("drop index: %v", err)
+	}
+	legacy := models.Review{PlayerID: pid, TargetType: models.ReviewTargetProvider, TargetID: vid, Rating: 1, PlayRecordID: &recID, Verified: true}
+	config.DB.Create(&legacy)
+	if err := config.AutoMigrate(); err != nil {
+		t.Fatalf("migrate with duplicate reviews: %v", err)
+	}
+	var linked int64
+	config.DB.Model(&models.Review{}).Where("play_record_id = ?", recID).Count(&linked)
+	config.DB.First(&legacy, legacy.ID)
+	if linked != 1 || legacy.PlayRecordID != nil || legacy.Verified {
+		t.Fatalf("after dedupe linked = %d, legacy = %+v", linked, legacy)
+	}
+	config.DB.Delete(&legacy)
+
+	// 未关联记录的评价仍可提交，但不是已验证
+	doReq(t, r, "POST", "/api/v1/player/reviews", otok, map[string]any{"target_type": "provider", "target_id": vid, "rating": 1})
+
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews/summary?target_type=provider&target_id=%d", vid), "", nil)
+	if d := mustData(t, resp); d["count"].(float64) != 2 || d["verified_count"].(float64) != 1 {
+		t.Fatalf("summary = %v", d)
+	}
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews/summary?target_type=provider&target_id=%d&verified=1", vid), "", nil)
+	if d := mustData(t, resp); d["count"].(float64) != 1 || d["average_rating"].(float64) != 5 {
+		t.Fatalf("verified-only summary = %v", d)
+	}
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews?target_type=provider&target_id=%d&verified=1", vid), "", nil)
+	if mustData(t, resp)["total"].(float64) != 1 {
+		t.Fatalf("verified-only list = %v", resp)
+	}
+}
+
+// --- 用户故事 5c：服务者 / 工作室回复评价，控制台列出待回复评价 ---
+
+func TestReviewReplies(t *testing.T) {
+	r := newTestApp(t)
+	ptok, _ := register(t, r, "player", "player18", "小柚")
+	vtok, vid := register(t, r, "provider", "prov18", "晚风")
+	otok, _ := register(t, r, "provider", "prov19", "路人")
+	stok, _ := register(t, r, "studio", "studio18", "星轨")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/studio/", stok, map[string]any{"name": "星轨陪玩"})
+	sid := uint(mustData(t, resp)["id"].(float64))
+
+	_, resp = doReq(t, r, "POST", "/api/v1/player/reviews", ptok, map[string]any{
+		"target_type": "provider", "target_id": vid, "rating": 2, "content": "迟到了", "is_anonymous": true,
+	})
+	providerReview := uint(mustData(t, resp)["id"].(float64))
+	_, resp = doReq(t, r, "POST", "/api/v1/player/reviews", ptok, map[string]any{
+		"target_type": "studio", "target_id": sid, "rating": 4, "content": "还行",
+	})
+	studioReview := uint(mustData(t, resp)["id"].(float64))
+
+	_, resp = doReq(t, r, "GET", "/api/v1/provider/dashboard", vtok, nil)
+	if d := mustData(t, resp); d["unanswered_count"].(float64) != 1 ||
+		d["unanswered_reviews"].([]any)[0].(map[string]any)["player_id"].(float64) != 0 {
+		t.Fatalf("provider unanswered = %v", d["unanswered_reviews"])
+	}
+
+	replyURL := fmt.Sprintf("/api/v1/reviews/%d/reply", providerReview)
+	code, _ := doReq(t, r, "POST", replyURL, otok, map[string]any{"content": "抱歉"})
+	if code != http.StatusForbidden {
+		t.Fatalf("reply by other provider = %d, want 403", code)
+	}
+	_, resp = doReq(t, r, "POST", replyURL, vtok, map[string]any{"content": "抱歉，下次准时"})
+	if resp["code"].(float64) != 0 {
+		t.Fatalf("reply failed: %v", resp)
+	}
+	_, resp = doReq(t, r, "POST", replyURL, vtok, map[string]any{"content": "再回复"})
+	if resp["code"].(float64) == 0 {
+		t.Fatal("second reply should fail")
+	}
+	doReq(t, r, "PUT", replyURL, vtok, map[string]any{"content": "抱歉，已改进"})
+
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews?target_type=provider&target_id=%d", vid), "", nil)
+	reply := mustData(t, resp)["list"].([]any)[0].(map[string]any)["reply"].(map[string]any)
+	if reply["content"] != "抱歉，已改进" {
+		t.Fatalf("listed reply = %v", reply)
+	}
+	_, resp = doReq(t, r, "GET", "/api/v1/provider/dashboard", vtok, nil)
+	if mustData(t, resp)["unanswered_count"].(float64) != 0 {
+		t.Fatal("answered review still listed as unanswered")
+	}
+
+	// 删除回复后重新变为待回复
+	doReq(t, r, "DELETE", replyURL, vtok, nil)
+	_, resp = doReq(t, r, "GET", "/api/v1/provider/dashboard", vtok, nil)
+	if mustData(t, resp)["unanswered_count"].(float64) != 1 {
+		t.Fatal("review should be unanswered after deleting reply")
+	}
+
+	// 工作室所有者回复工作室评价
+	_, resp = doReq(t, r, "GET", "/api/v1/studio/dashboard", stok, nil)
+	if mustData(t, resp)["unanswered_count"].(float64) != 1 {
+		t.Fatalf("studio unanswered = %v", mustData(t, resp)["unanswered_count"])
+	}
+	_, resp = doReq(t, r, "POST", fmt.Sprintf("/api/v1/reviews/%d/reply", studioReview), stok, map[string]any{"content": "感谢支持"})
+	if resp["code"].(float64) != 0 {
+		t.Fatalf("studio reply failed: %v", resp)
+	}
+	_, resp = doReq(t, r, "GET", "/api/v1/studio/dashboard", stok, nil)
+	if mustData(t, resp)["unanswered_count"].(float64) != 0 {
+		t.Fatal("studio review should be answered")
+	}
+}
+
+// --- 用户故事 5d：评价举报与审核队列（隐藏 / 恢复 / 删除，保留审核历史） ---
+
+func TestReviewModeration(t *testing.T) {
+	r := newTestApp(t)
+	ptok, _ := register(t, r, "player", "player20", "小柚")
+	otok, _ := register(t, r, "player", "player21", "阿离")
+	_, vid := register(t, r, "provider", "prov20", "晚风")
+	atok := registerAdmin(t, r, "admin20", "管理员")
+	stok, _ := register(t, r, "studio", "studio20", "星轨")
+
+	var ids []uint
+	for _, rating := range []int{1, 5} {
+		_, resp := doReq(t, r, "POST", "/api/v1/player/reviews", ptok, map[string]any{
+			"target_type": "provider", "target_id": vid, "rating": rating,
+		})
+		ids = append(ids, uint(mustData(t, resp)["id"].(float64)))
+	}
+	fake := ids[0]
+	summary := func() map[string]any {
+		_, resp := doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews/summary?target_type=provider&target_id=%d", vid), "", nil)
+		return mustData(t, resp)
+	}
+
+	reportURL := fmt.Sprintf("/api/v1/reviews/%d/report", fake)
+	for _, tok := range []string{otok, atok} {
+		if _, resp := doReq(t, r, "POST", reportURL, tok, map[string]any{"reason": "恶意差评"}); resp["code"].(float64) != 0 {
+			t.Fatalf("report failed: %v", resp)
+		}
+	}
+	if _, resp := doReq(t, r, "POST", reportURL, otok, map[string]any{"reason": "再举报"}); resp["code"].(float64) == 0 {
+		t.Fatal("duplicate report should fail")
+	}
+
+	_, resp := doReq(t, r, "GET", "/api/v1/admin/reviews/queue", atok, nil)
+	queue := mustData(t, resp)["list"].([]any)
+	if len(queue) != 1 || len(queue[0].(map[string]any)["reports"].([]any)) != 2 {
+		t.Fatalf("queue = %v", queue)
+	}
+
+	// 隐藏：从公开列表与汇总中排除，举报结案
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/admin/reviews/%d/hide", fake), atok, map[string]any{"notes": "刷差评"})
+	if d := summary(); d["count"].(float64) != 1 || d["average_rating"].(float64) != 5 {
+		t.Fatalf("summary after hide = %v", d)
+	}
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews?target_type=provider&target_id=%d", vid), "", nil)
+	if mustData(t, resp)["total"].(float64) != 1 {
+		t.Fatal("hidden review should not be listed")
+	}
+	_, resp = doReq(t, r, "GET", "/api/v1/admin/reviews/queue", atok, nil)
+	if mustData(t, resp)["total"].(float64) != 0 {
+		t.Fatal("queue should be empty after moderation")
+	}
+
+	// 恢复后重新计入；删除后再次排除，仍可恢复
+	doReq(t, r, "PUT", fmt.Sprintf("/api/v1/admin/reviews/%d/restore", fake), atok, nil)
+	if summary()["count"].(float64) != 2 {
+		t.Fatal("restored review should count again")
+	}
+	doReq(t, r, "DELETE", fmt.Sprintf("/api/v1/admin/reviews/%d", fake), atok, map[string]any{"notes": "确认虚假"})
+	if summary()["count"].(float64) != 1 {
+		t.Fatal("deleted review should not count")
+	}
+	if _, resp := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/admin/reviews/%d/hide", fake), atok, nil); resp["code"].(float64) == 0 {
+		t.Fatal("hiding a deleted review should fail")
+	}
+
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/admin/reviews/%d/moderations", fake), atok, nil)
+	d := mustData(t, resp)
+	var actions []string
+	for _, m := range d["moderations"].([]any) {
+		actions = append(actions, m.(map[string]any)["action"].(string))
+	}
+	if strings.Join(actions, ",") != "hide,restore,delete" || d["deleted"] != true || len(d["reports"].([]any)) != 2 {
+		t.Fatalf("history = %v", d)
+	}
+
+	// 普通用户不能审核
+	code, _ := doReq(t, r, "PUT", fmt.Sprintf("/api/v1/admin/reviews/%d/hide", ids[1]), otok, nil)
+	if code != http.StatusForbidden {
+		t.Fatalf("moderation by player = %d, want 403", code)
+	}
+	// 工作室账号同样不能审核
+	code, _ = doReq(t, r, "PUT", fmt.Sprintf("/api/v1/admin/reviews/%d/hide", ids[1]), stok, nil)
+	if code != http.StatusForbidden {
+		t.Fatalf("moderation by studio = %d, want 403", code)
+	}
+}
+
+// --- 用户故事 5e：结构化评价标签与标签统计 ---
+
+func TestReviewTags(t *testing.T) {
+	r := newTestApp(t)
+	ptok, pid := register(t, r, "player", "player22", "小柚")
+	_, vid := register(t, r, "provider", "prov22", "晚风")
+
+	_, resp := doReq(t, r, "GET", "/api/v1/review-tags", "", nil)
+	if d := mustData(t, resp); len(d["positive"].([]any)) == 0 || len(d["negative"].([]any)) == 0 {
+		t.Fatalf("tag vocabulary = %v", d)
+	}
+
+	_, resp = doReq(t, r, "POST", "/api/v1/player/reviews", ptok, map[string]any{
+		"target_type": "provider", "target_id": vid, "rating": 3, "tags": []string{"不存在的标签"},
+	})
+	if resp["code"].(float64) == 0 {
+		t.Fatal("unknown tag should be rejected")
+	}
+	for _, tags := range [][]string{{"准时", "技术好"}, {"准时", "准时"}} {
+		_, resp = doReq(t, r, "POST", "/api/v1/player/reviews", ptok, map[string]any{
+			"target_type": "provider", "target_id": vid, "rating": 5, "tags": tags,
+		})
+		if resp["code"].(float64) != 0 {
+			t.Fatalf("create tagged review failed: %v", resp)
+		}
+	}
+
+	// 历史自由文本标签迁移到词表
+	config.DB.Create(&models.Review{PlayerID: pid, TargetType: models.ReviewTargetProvider, TargetID: vid,
+		Rating: 2, LegacyTags: `["迟到","准时","随便写的"]`})
+	report, err := controllers.MigrateLegacyReviewTags(config.DB)
+	if err != nil || report.Reviews != 1 || len(report.Unmatched) != 1 {
+		t.Fatalf("legacy migration = %+v, %v", report, err)
+	}
+
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews/summary?target_type=provider&target_id=%d", vid), "", nil)
+	tags := mustData(t, resp)["tags"].([]any)
+	top := tags[0].(map[string]any)
+	if len(tags) != 3 || top["name"] != "准时" || top["count"].(float64) != 3 || top["polarity"] != "positive" {
+		t.Fatalf("tag counts = %v", tags)
+	}
+
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/providers/%d", vid), "", nil)
+	if top := mustData(t, resp)["top_tags"].([]any)[0].(map[string]any); top["name"] != "准时" {
+		t.Fatalf("profile top tags = %v", top)
+	}
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews?target_type=provider&target_id=%d", vid), "", nil)
+	for _, item := range mustData(t, resp)["list"].([]any) {
+		if len(item.(map[string]any)["tags"].([]any)) == 0 {
+			t.Fatalf("listed review missing tags: %v", item)
+		}
+	}
+}
+
+// --- 用户故事 5f：排名分（贝叶斯平滑 + 时间衰减）与按排名排序 ---
+
+func TestRatingRanking(t *testing.T) {
+	r := newTestApp(t)
+	ptok, pid := register(t, r, "player", "player23", "小柚")
+	_, single := register(t, r, "provider", "prov23", "一条好评")
+	_, many := register(t, r, "provider", "prov24", "大量好评")
+	_, low := register(t, r, "provider", "prov25", "差评")
+	_, aged := register(t, r, "provider", "prov26", "旧差评")
+	_, unrated := register(t, r, "provider", "prov27", "暂无评价")
+	stok, _ := register(t, r, "studio", "studio23", "星轨")
+
+	review := func(targetType string, id uint, rating int) {
+		_, resp := doReq(t, r, "POST", "/api/v1/player/reviews", ptok, map[string]any{
+			"target_type": targetType, "target_id": id, "rating": rating,
+		})
+		if resp["code"].(float64) != 0 {
+			t.Fatalf("create review failed: %v", resp)
+		}
+	}
+	review("provider", single, 5)
+	for _, rating := range []int{5, 5, 5, 4} {
+		review("provider", many, rating)
+	}
+	for i := 0; i < 3; i++ {
+		review("provider", low, 1)
+	}
+
+	// 两年前的差评权重已衰减到很小
+	config.DB.Create(&models.Review{PlayerID: pid, TargetType: models.ReviewTargetProvider, TargetID: aged,
+		Rating: 1, Status: models.ReviewStatusVisible, CreatedAt: time.Now().AddDate(-2, 0, 0)})
+	review("provider", aged, 5)
+
+	// 定时任务按最新平台均分重算全部排名分
+	n, err := controllers.RefreshStaleRatingScores(time.Now().Add(48 * time.Hour))
+	if err != nil || n != 4 {
+		t.Fatalf("refresh stale scores = %d, %v", n, err)
+	}
+
+	_, resp := doReq(t, r, "GET", "/api/v1/providers?sort=ranking", "", nil)
+	list := mustData(t, resp)["list"].([]any)
+	ids := make([]uint, 0, len(list))
+	scores := map[uint]map[string]any{}
+	for _, item := range list {
+		p := item.(map[string]any)
+		id := uint(p["id"].(float64))
+		ids = append(ids, id)
+		if rs, ok := p["rating_score"].(map[string]any); ok {
+			scores[id] = rs
+		}
+	}
+	// 单条 5 星不应排在大量高分之前；差评最后
+	if ids[0] != many || ids[len(ids)-1] != low {
+		t.Fatalf("ranking order = %v (many=%d, low=%d)", ids, many, low)
+	}
+	if scores[single]["score"].(float64) >= scores[many]["score"].(float64) {
+		t.Fatalf("single review outranks many: %v vs %v", scores[single], scores[many])
+	}
+	if avg := scores[aged]["decayed_average"].(float64); avg < 4.5 {
+		t.Fatalf("aged 1-star should be decayed, decayed_average = %v", avg)
+	}
+	// 暂无评价的对象按平台均分参与排序：在高分之后、差评之前
+	pos := map[uint]int{}
+	for i, id := range ids {
+		pos[id] = i
+	}
+	if pos[unrated] <= pos[many] || pos[unrated] >= pos[low] {
+		t.Fatalf("unrated provider position = %v", ids)
+	}
+
+	// 平台均分明显变动后，其他对象的排名分无需等到过期即重算
+	for i := 0; i < 5; i++ {
+		review("provider", low, 1)
+	}
+	if n, err := controllers.RefreshStaleRatingScores(time.Now()); err != nil || n != 3 {
+		t.Fatalf("refresh after platform mean drift = %d, %v; want 3", n, err)
+	}
+	if n, _ := controllers.RefreshStaleRatingScores(time.Now()); n != 0 {
+		t.Fatalf("scores refreshed again without drift: %d", n)
+	}
+
+	// 排名分表上线前的旧评价：定时任务为没有排名分的对象补算
+	config.DB.Create(&models.Review{PlayerID: pid, TargetType: models.ReviewTargetProvider, TargetID: unrated,
+		Rating: 4, Status: models.ReviewStatusVisible})
+	if n, err := controllers.BackfillRatingScores(time.Now()); err != nil || n != 1 {
+		t.Fatalf("backfill rating scores = %d, %v; want 1", n, err)
+	}
+	var backfilled models.RatingScore
+	if err := config.DB.Where("target_type = ? AND target_id = ?", models.ReviewTargetProvider, unrated).First(&backfilled).Error; err != nil || backfilled.ReviewCount != 1 {
+		t.Fatalf("backfilled score = %+v, %v", backfilled, err)
+	}
+	if n, _ := controllers.BackfillRatingScores(time.Now()); n != 0 {
+		t.Fatalf("backfill ran again for scored targets: %d", n)
+	}
+
+	// 工作室列表按排名分排序
+	var studios []uint
+	for _, name := range []string{"甲工作室", "乙工作室"} {
+		_, resp := doReq(t, r, "POST", "/api/v1/studio/", stok, map[string]any{"name": name})
+		studios = append(studios, uint(mustData(t, resp)["id"].(float64)))
+	}
+	review("studio", studios[0], 2)
+	for i := 0; i < 3; i++ {
+		review("studio", studios[1], 5)
+	}
+	_, resp = doReq(t, r, "GET", "/api/v1/studios?sort=ranking", "", nil)
+	first := mustData(t, resp)["list"].([]any)[0].(map[string]any)
+	if uint(first["id"].(float64)) != studios[1] || first["rating_score"] == nil {
+		t.Fatalf("studio ranking first = %v", first)
+	}
+}
+
+// --- 用户故事 5g：评价修改时限与修改历史 ---
+
+func TestReviewEditing(t *testing.T) {
+	r := newTestApp(t)
+	ptok, _ := register(t, r, "player", "player27", "小柚")
+	otok, _ := register(t, r, "player", "player28", "阿离")
+	_, vid := register(t, r, "provider", "prov27", "晚风")
+
+	_, resp := doReq(t, r, "POST", "/api/v1/player/reviews", ptok, map[string]any{
+		"target_type": "provider", "target_id": vid, "rating": 2, "content": "迟到了", "tags": []string{"迟到"},
+	})
+	reviewID := uint(mustData(t, resp)["id"].(float64))
+	editURL := fmt.Sprintf("/api/v1/player/reviews/%d", reviewID)
+	edit := map[string]any{"rating": 5, "content": "沟通后解决了，很满意", "tags": []string{"准时", "耐心"}}
+
+	code, _ := doReq(t, r, "PUT", editURL, otok, edit)
+	if code != http.StatusForbidden {
+		t.Fatalf("edit by other player = %d, want 403", code)
+	}
+	_, resp = doReq(t, r, "PUT", editURL, ptok, edit)
+	if d := mustData(t, resp); d["edit_count"].(float64) != 1 || d["edited_at"] == nil || len(d["tags"].([]any)) != 2 {
+		t.Fatalf("edited review = %v", d)
+	}
+
+	// 汇总与标签统计按最新版本
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews/summary?target_type=provider&target_id=%d", vid), "", nil)
+	d := mustData(t, resp)
+	if d["average_rating"].(float64) != 5 || len(d["tags"].([]any)) != 2 {
+		t.Fatalf("summary after edit = %v", d)
+	}
+	_, resp = doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews?target_type=provider&target_id=%d", vid), "", nil)
+	if item := mustData(t, resp)["list"].([]any)[0].(map[string]any); item["edit_count"].(float64) != 1 {
+		t.Fatalf("listing should mark edited: %v", item)
+	}
+
+	_, resp = doReq(t, r, "GET", editURL+"/revisions", ptok, nil)
+	revs := resp["data"].([]any)
+	if len(revs) != 1 {
+		t.Fatalf("revisions = %v", revs)
+	}
+	if rev := revs[0].(map[string]any); rev["rating"].(float64) != 2 || rev["tag_names"] != "迟到" || rev["content"] != "迟到了" {
+		t.Fatalf("revision = %v", rev)
+	}
+
+	// 超过修改时限
+	t.Setenv("REVIEW_EDIT_WINDOW", "1ns")
+	_, resp = doReq(t, r, "PUT", editURL, ptok, edit)
+	if resp["code"].(float64) == 0 {
+		t.Fatal("edit after window should fail")
+	}
+}
+
+// --- 用户故事 5h：评价「有帮助」投票与排序筛选 ---
+
+func TestReviewHelpfulAndSorting(t *testing.T) {
+	r := newTestApp(t)
+	p1, _ := register(t, r, "player", "player29", "一号")
+	p2, _ := register(t, r, "player", "player30", "二号")
+	p3, _ := register(t, r, "player", "player31", "三号")
+	_, vid := register(t, r, "provider", "prov29", "星河")
+
+	post := func(tok string, rating int, content string) uint {
+		_, resp := doReq(t, r, "POST", "/api/v1/player/reviews", tok, map[string]any{
+			"target_type": "provider", "target_id": vid, "rating": rating, "content": content,
+		})
+		return uint(mustData(t, resp)["id"].(float64))
+	}
+	low := post(p1, 2, "一般")
+	high := post(p2, 5, "")
+	mid := post(p3, 4, "还不错")
+
+	helpful := func(tok string, id uint) (int, map[string]any) {
+		return doReq(t, r, "POST", fmt.Sprintf("/api/v1/reviews/%d/helpful", id), tok, nil)
+	}
+	if _, resp := helpful(p1, low); resp["code"].(float64) == 0 {
+		t.Fatal("voting own review should fail")
+	}
+	helpful(p1, mid)
+	_, resp := helpful(p2, mid)
+	if mustData(t, resp)["helpful_count"].(float64) != 2 {
+		t.Fatalf("helpful count = %v", resp)
+	}
+	if _, resp := helpful(p2, mid); resp["code"].(float64) == 0 {
+		t.Fatal("second vote by same user should fail")
+	}
+	helpful(p3, low)
+
+	ids := func(query string) []uint {
+		_, resp := doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews?target_type=provider&target_id=%d%s", vid, query), "", nil)
+		var out []uint
+		for _, item := range mustData(t, resp)["list"].([]any) {
+			out = append(out, uint(item.(map[string]any)["id"].(float64)))
+		}
+		return out
+	}
+	check := func(query string, want ...uint) {
+		t.Helper()
+		if got := ids(query); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("list%s = %v, want %v", query, got, want)
+		}
+	}
+	check("&sort=helpful", mid, low, high)
+	check("&sort=rating_desc", high, mid, low)
+	check("&sort=rating_asc", low, mid, high)
+	check("&rating=5", high)
+	check("&has_content=1&sort=rating_desc", mid, low)
+
+	// 撤销投票后排序随之变化
+	doReq(t, r, "DELETE", fmt.Sprintf("/api/v1/reviews/%d/helpful", mid), p1, nil)
+	doReq(t, r, "DELETE", fmt.Sprintf("/api/v1/reviews/%d/helpful", mid), p2, nil)
+	check("&sort=helpful", low, mid, high)
+	if code, _ := doReq(t, r, "DELETE", fmt.Sprintf("/api/v1/reviews/%d/helpful", mid), p2, nil); code != http.StatusNotFound {
+		t.Fatalf("unvote without vote = %d, want 404", code)
+	}
+	if code, _ := doReq(t, r, "GET", fmt.Sprintf("/api/v1/reviews?target_type=provider&target_id=%d&sort=random", vid), "", nil); code != http.StatusBadRequest {
+		t.Fatalf("unknown sort = %d, want 400", code)
 	}
 }
 
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"flag"
 	"log"
 
 	"companion-platform-backend/config"
+	"companion-platform-backend/controllers"
 	"companion-platform-backend/routes"
 	"companion-platform-backend/utils"
 
@@ -12,6 +14,10 @@ import (
 )
 
 func main() {
+	migrateGames := flag.Bool("migrate-games", false, "将历史游玩记录的自由文本游戏/模式映射到游戏目录后退出")
+	migrateReviewTags := flag.Bool("migrate-review-tags", false, "将历史评价的自由文本标签关联到标签词表后退出")
+	flag.Parse()
+
 	// 加载 .env（若存在）。已存在的进程环境变量优先，不会被覆盖。
 	_ = godotenv.Load()
 
@@ -32,6 +38,29 @@ func main() {
 		log.Fatal("Failed to connect database:", err)
 	}
 
+	if *migrateGames {
+		report, err := controllers.MapPlayRecordsToCatalog(config.GetDB())
+		if err != nil {
+			log.Fatal("Failed to map play records to game catalog:", err)
+		}
+		log.Printf("Mapped %d play records; unmatched games: %v; unmatched modes: %v",
+			report.Mapped, report.UnmatchedGames, report.UnmatchedModes)
+		return
+	}
+
+	if *migrateReviewTags {
+		report, err := controllers.MigrateLegacyReviewTags(config.GetDB())
+		if err != nil {
+			log.Fatal("Failed to migrate review tags:", err)
+		}
+		log.Printf("Linked tags for %d reviews; unmatched tags: %v", report.Reviews, report.Unmatched)
+		return
+	}
+
+	// 启动后台定时任务（确认窗口到期自动确认等）
+	stopScheduler := controllers.StartScheduler(cfg.Schedule.Interval)
+	defer stopScheduler()
+
 	// 创建Gin引擎
 	r := gin.New()
 
@@ -47,4 +76,4 @@ func main() {
 	if err := r.Run(":" + cfg.Server.Port); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
-}
\ No newline at end of file
+}
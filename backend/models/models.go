@@ -14,6 +14,7 @@ const (
 	RolePlayer   UserRole = "player"   // 玩家
 	RoleProvider UserRole = "provider" // 陪玩服务者
 	RoleStudio   UserRole = "studio"   // 工作室
+	RoleAdmin    UserRole = "admin"    // 平台管理员：不可注册，由 ADMIN_USERNAMES 指定
 )
 
 // User 用户表
@@ -36,25 +37,116 @@ type User struct {
 	Balances          []Balance                `json:"balances,omitempty" gorm:"foreignKey:PlayerID"`
 	PlayRecords       []PlayRecord             `json:"play_records,omitempty" gorm:"foreignKey:PlayerID"`
 	Reviews           []Review                 `json:"reviews,omitempty" gorm:"foreignKey:PlayerID"`
+	RatingScore       *RatingScore             `json:"rating_score,omitempty" gorm:"polymorphic:Target;polymorphicValue:provider"` // 服务者排名分
 }
 
 // Studio 工作室表
 type Studio struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	Name        string         `json:"name" gorm:"not null;size:100;index"`
-	Description string         `json:"description" gorm:"type:text"`
-	Logo        string         `json:"logo" gorm:"size:255"`
-	ContactInfo string         `json:"contact_info" gorm:"type:text"`
-	IsActive    bool           `json:"is_active" gorm:"default:true"`
-	OwnerID     uint           `json:"owner_id" gorm:"not null;index"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                 uint                `json:"id" gorm:"primaryKey"`
+	Name               string              `json:"name" gorm:"not null;size:100;index"`
+	Description        string              `json:"description" gorm:"type:text"`
+	Logo               string              `json:"logo" gorm:"size:255"`
+	ContactInfo        string              `json:"contact_info" gorm:"type:text"`
+	IsActive           bool                `json:"is_active" gorm:"default:true"`
+	OwnerID            uint                `json:"owner_id" gorm:"not null;index"`
+	DeactivatedAt      *time.Time          `json:"deactivated_at"` // 最近一次停用时间，重新启用后清空
+	DeactivationReason string              `json:"deactivation_reason" gorm:"type:text"`
+	BalancePolicy      StudioBalancePolicy `json:"balance_policy" gorm:"size:20"` // 停用时对名下玩家余额采取的处理方式
+	CreatedAt          time.Time           `json:"created_at"`
+	UpdatedAt          time.Time           `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt      `json:"-" gorm:"index"`
 
 	// 关联
-	Owner     User                     `json:"owner" gorm:"foreignKey:OwnerID"`
-	Relations []ProviderStudioRelation `json:"relations,omitempty" gorm:"foreignKey:StudioID"`
-	Reviews   []Review                 `json:"reviews,omitempty" gorm:"-"` // 多态评价，按 target_type/target_id 手动查询
+	Owner       User                     `json:"owner" gorm:"foreignKey:OwnerID"`
+	Relations   []ProviderStudioRelation `json:"relations,omitempty" gorm:"foreignKey:StudioID"`
+	Reviews     []Review                 `json:"reviews,omitempty" gorm:"-"` // 多态评价，按 target_type/target_id 手动查询
+	RatingScore *RatingScore             `json:"rating_score,omitempty" gorm:"polymorphic:Target;polymorphicValue:studio"`
+}
+
+// StudioBalancePolicy 工作室停用时名下玩家余额的处理方式
+type StudioBalancePolicy string
+
+const (
+	BalancePolicyBlock    StudioBalancePolicy = "block"    // 冻结：余额保留，停用期间不可变动，重新启用后恢复
+	BalancePolicyTransfer StudioBalancePolicy = "transfer" // 转移：转入对应服务者的个人余额
+	BalancePolicyRefund   StudioBalancePolicy = "refund"   // 退还：余额清零并记退还流水（线下退给玩家）
+)
+
+// StaffRole 工作室员工角色枚举（所有者不在员工表中，拥有全部权限）
+type StaffRole string
+
+const (
+	StaffManager  StaffRole = "manager"  // 经理：除员工管理外的全部权限
+	StaffFinance  StaffRole = "finance"  // 财务：余额操作、申诉处理与记录修正
+	StaffReviewer StaffRole = "reviewer" // 审核员：处理加入申请与邀请码
+)
+
+// StudioPermission 工作室权限枚举
+type StudioPermission string
+
+const (
+	PermStudioView         StudioPermission = "view"         // 查看控制台、成员与记录
+	PermStudioEdit         StudioPermission = "edit"         // 修改工作室信息与取消策略
+	PermStudioApplications StudioPermission = "applications" // 查看 / 处理加入申请，管理邀请码
+	PermStudioMembers      StudioPermission = "members"      // 移除成员
+	PermStudioBalance      StudioPermission = "balance"      // 充值 / 扣费 / 退款、处理申诉、修正记录
+	PermStudioReviews      StudioPermission = "reviews"      // 回复工作室收到的评价
+	PermStudioStaff        StudioPermission = "staff"        // 管理员工（仅所有者）
+)
+
+// AllStudioPermissions 全部工作室权限（所有者拥有）
+var AllStudioPermissions = []StudioPermission{PermStudioView, PermStudioEdit, PermStudioApplications,
+	PermStudioMembers, PermStudioBalance, PermStudioReviews, PermStudioStaff}
+
+// StaffRolePermissions 各员工角色拥有的权限
+var StaffRolePermissions = map[StaffRole][]StudioPermission{
+	StaffManager: {PermStudioView, PermStudioEdit, PermStudioApplications, PermStudioMembers,
+		PermStudioBalance, PermStudioReviews},
+	StaffFinance:  {PermStudioView, PermStudioBalance},
+	StaffReviewer: {PermStudioView, PermStudioApplications},
+}
+
+// StudioStaff 工作室员工：(studio_id, user_id) 唯一，员工须为工作室角色账号
+type StudioStaff struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	StudioID  uint      `json:"studio_id" gorm:"not null;uniqueIndex:idx_studio_staff,priority:1"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_studio_staff,priority:2;index"`
+	Role      StaffRole `json:"role" gorm:"not null;size:20"`
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 关联
+	User   User   `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Studio Studio `json:"studio,omitempty" gorm:"foreignKey:StudioID"`
+}
+
+// TransferStatus 工作室转让状态枚举
+type TransferStatus string
+
+const (
+	TransferPending   TransferStatus = "pending"   // 等待接收方确认
+	TransferAccepted  TransferStatus = "accepted"  // 已接收，所有权已转移
+	TransferDeclined  TransferStatus = "declined"  // 接收方拒绝
+	TransferCancelled TransferStatus = "cancelled" // 发起方撤回
+)
+
+// StudioTransfer 工作室所有权转让：所有者发起，接收方确认后生效；记录保留作为历史
+type StudioTransfer struct {
+	ID                uint           `json:"id" gorm:"primaryKey"`
+	StudioID          uint           `json:"studio_id" gorm:"not null;index"`
+	FromUserID        uint           `json:"from_user_id" gorm:"not null"`
+	ToUserID          uint           `json:"to_user_id" gorm:"not null;index"`
+	Status            TransferStatus `json:"status" gorm:"not null;size:20;default:'pending';index"`
+	PreviousOwnerRole StaffRole      `json:"previous_owner_role" gorm:"size:20"` // 转让后原所有者留任的员工角色，空为不留任
+	Notes             string         `json:"notes" gorm:"type:text"`
+	RespondedAt       *time.Time     `json:"responded_at"`
+	CreatedAt         time.Time      `json:"created_at"`
+
+	// 关联
+	Studio   Studio `json:"studio,omitempty" gorm:"foreignKey:StudioID"`
+	FromUser User   `json:"from_user,omitempty" gorm:"foreignKey:FromUserID"`
+	ToUser   User   `json:"to_user,omitempty" gorm:"foreignKey:ToUserID"`
 }
 
 // RelationStatus 关联状态枚举
@@ -64,25 +156,93 @@ const (
 	StatusPending  RelationStatus = "pending"  // 待审核
 	StatusApproved RelationStatus = "approved" // 已批准
 	StatusRejected RelationStatus = "rejected" // 已拒绝
+	StatusRemoved  RelationStatus = "removed"  // 被工作室移除
+	StatusLeft     RelationStatus = "left"     // 服务者主动退出（或撤回申请）
 )
 
 // ProviderStudioRelation 服务者-工作室关联表
 // (provider_id, studio_id) 唯一：一个服务者对一个工作室只保留一条关系记录，状态在其上流转。
+// 流转：pending → approved / rejected / left，approved → removed / left，rejected / removed / left → pending（重新申请）
 type ProviderStudioRelation struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	ProviderID  uint           `json:"provider_id" gorm:"not null;uniqueIndex:idx_provider_studio,priority:1"`
-	StudioID    uint           `json:"studio_id" gorm:"not null;uniqueIndex:idx_provider_studio,priority:2"`
-	Status      RelationStatus `json:"status" gorm:"not null;default:'pending';size:20;index"`
-	AppliedAt   time.Time      `json:"applied_at"`
-	ProcessedAt *time.Time     `json:"processed_at"`
-	Notes       string         `json:"notes" gorm:"type:text"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
-
-	// 关联
-	Provider User   `json:"provider" gorm:"foreignKey:ProviderID"`
-	Studio   Studio `json:"studio,omitempty" gorm:"foreignKey:StudioID"`
+	ID                uint              `json:"id" gorm:"primaryKey"`
+	ProviderID        uint              `json:"provider_id" gorm:"not null;uniqueIndex:idx_provider_studio,priority:1"`
+	StudioID          uint              `json:"studio_id" gorm:"not null;uniqueIndex:idx_provider_studio,priority:2"`
+	Status            RelationStatus    `json:"status" gorm:"not null;default:'pending';size:20;index"`
+	AppliedAt         time.Time         `json:"applied_at"`
+	ProcessedAt       *time.Time        `json:"processed_at"`
+	Notes             string            `json:"notes" gorm:"type:text"`
+	StatusChangedAt   *time.Time        `json:"status_changed_at"`                                             // 最近一次状态流转时间
+	StatusChangedBy   uint              `json:"status_changed_by"`                                             // 最近一次状态流转的操作人
+	StatusReason      string            `json:"status_reason" gorm:"type:text"`                                // 最近一次状态流转的原因（移除、退出等）
+	InviteID          *uint             `json:"invite_id" gorm:"index"`                                        // 通过邀请码加入时记录所用邀请
+	ApplicationFields map[string]string `json:"application_fields,omitempty" gorm:"type:text;serializer:json"` // 按工作室申请策略填写的资料
+	TermsAcceptedAt   *time.Time        `json:"terms_accepted_at"`
+	RuleFired         ApplicationRule   `json:"rule_fired" gorm:"size:30"`    // 申请时触发的自动规则，空为无
+	RuleDetail        string            `json:"rule_detail" gorm:"type:text"` // 规则判定依据（评分、完成局数等）
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt    `json:"-" gorm:"index"`
+
+	// 关联
+	Provider User                `json:"provider" gorm:"foreignKey:ProviderID"`
+	Studio   Studio              `json:"studio,omitempty" gorm:"foreignKey:StudioID"`
+	History  []RelationStatusLog `json:"history,omitempty" gorm:"foreignKey:RelationID"`
+}
+
+// StudioInvite 工作室邀请码：可限定使用次数、有效期或指定用户名，服务者兑换后自动加入工作室
+type StudioInvite struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	StudioID  uint       `json:"studio_id" gorm:"not null;index"`
+	Code      string     `json:"code" gorm:"not null;size:32;uniqueIndex"`
+	CreatedBy uint       `json:"created_by" gorm:"not null"`
+	Username  string     `json:"username" gorm:"size:50"`            // 非空时仅该用户名可兑换（定向邀请）
+	MaxUses   int        `json:"max_uses" gorm:"not null;default:0"` // 0 表示不限次数，1 为一次性邀请
+	UsedCount int        `json:"used_count" gorm:"not null;default:0"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	Notes     string     `json:"notes" gorm:"type:text"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// 关联
+	Studio Studio `json:"studio,omitempty" gorm:"foreignKey:StudioID"`
+}
+
+// ApplicationRule 申请自动规则枚举
+type ApplicationRule string
+
+const (
+	RuleAutoApprove  ApplicationRule = "auto_approve"  // 满足全部要求，自动批准
+	RuleAutoReject   ApplicationRule = "auto_reject"   // 未满足要求，自动拒绝
+	RuleManualReview ApplicationRule = "manual_review" // 未满足要求，转人工审核
+)
+
+// StudioApplicationPolicy 工作室加入申请策略：条款、必填资料与准入门槛，以及满足 / 未满足时的自动处理
+type StudioApplicationPolicy struct {
+	ID                   uint      `json:"id" gorm:"primaryKey"`
+	StudioID             uint      `json:"studio_id" gorm:"not null;uniqueIndex"`
+	Terms                string    `json:"terms" gorm:"type:text"`                           // 服务者条款，非空时申请须同意
+	RequiredFields       []string  `json:"required_fields" gorm:"type:text;serializer:json"` // 申请时必须填写的资料项
+	MinRating            float64   `json:"min_rating"`                                       // 最低评分（公开评价均分），0 为不限
+	MinCompletedSessions int       `json:"min_completed_sessions"`                           // 最少已完成陪玩局数，0 为不限
+	AutoApprove          bool      `json:"auto_approve"`                                     // 满足全部门槛时自动批准
+	AutoReject           bool      `json:"auto_reject"`                                      // 未满足门槛时自动拒绝（否则转人工审核）
+	UpdatedBy            uint      `json:"updated_by"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// RelationStatusLog 关联状态流转历史（只追加）：申请、审批、移除、退出、重新申请各记一条
+type RelationStatusLog struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	RelationID uint           `json:"relation_id" gorm:"not null;index"`
+	ActorID    uint           `json:"actor_id" gorm:"not null"`   // 0 为系统（申请自动规则）
+	FromStatus RelationStatus `json:"from_status" gorm:"size:20"` // 首次申请时为空
+	ToStatus   RelationStatus `json:"to_status" gorm:"not null;size:20"`
+	Note       string         `json:"note" gorm:"type:text"`
+	CreatedAt  time.Time      `json:"created_at"`
+
+	// 关联
+	Actor User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
 }
 
 // BalanceType 余额类型枚举
@@ -118,11 +278,16 @@ type Balance struct {
 type TransactionType string
 
 const (
-	TransactionTypeRecharge TransactionType = "recharge" // 充值
-	TransactionTypeConsume  TransactionType = "consume"  // 消费
-	TransactionTypeRefund   TransactionType = "refund"   // 退款
-	TransactionTypeFreeze   TransactionType = "freeze"   // 冻结
-	TransactionTypeUnfreeze TransactionType = "unfreeze" // 解冻
+	TransactionTypeRecharge      TransactionType = "recharge"       // 充值
+	TransactionTypeConsume       TransactionType = "consume"        // 消费
+	TransactionTypeRefund        TransactionType = "refund"         // 退款
+	TransactionTypeFreeze        TransactionType = "freeze"         // 冻结
+	TransactionTypeUnfreeze      TransactionType = "unfreeze"       // 解冻
+	TransactionTypeTip           TransactionType = "tip"            // 打赏（从余额支付）
+	TransactionTypeCancelFee     TransactionType = "cancel_fee"     // 取消费 / 爽约费
+	TransactionTypeTransferOut   TransactionType = "transfer_out"   // 工作室停用，余额转出
+	TransactionTypeTransferIn    TransactionType = "transfer_in"    // 工作室停用，余额转入服务者个人余额
+	TransactionTypeClosureRefund TransactionType = "closure_refund" // 工作室停用，余额退还玩家
 )
 
 // BalanceTransaction 余额变动记录表
@@ -130,9 +295,9 @@ type BalanceTransaction struct {
 	ID           uint            `json:"id" gorm:"primaryKey"`
 	BalanceID    uint            `json:"balance_id" gorm:"not null;index:idx_tx_balance"`
 	Type         TransactionType `json:"type" gorm:"not null;size:20;index"`
-	Amount       decimal.Decimal `json:"amount" gorm:"type:decimal(14,2);not null"`            // 本次变动（正数）
-	BeforeAmount decimal.Decimal `json:"before_amount" gorm:"type:decimal(14,2);not null"`     // 变动前
-	AfterAmount  decimal.Decimal `json:"after_amount" gorm:"type:decimal(14,2);not null"`      // 变动后
+	Amount       decimal.Decimal `json:"amount" gorm:"type:decimal(14,2);not null"`        // 本次变动（正数）
+	BeforeAmount decimal.Decimal `json:"before_amount" gorm:"type:decimal(14,2);not null"` // 变动前
+	AfterAmount  decimal.Decimal `json:"after_amount" gorm:"type:decimal(14,2);not null"`  // 变动后
 	Description  string          `json:"description" gorm:"size:255"`
 	OperatorID   uint            `json:"operator_id" gorm:"index"` // 操作者ID
 	CreatedAt    time.Time       `json:"created_at" gorm:"index"`
@@ -146,33 +311,290 @@ type BalanceTransaction struct {
 type PlayStatus string
 
 const (
+	PlayStatusBooked    PlayStatus = "booked"    // 已预约（订阅排期生成，尚未开始）
 	PlayStatusActive    PlayStatus = "active"    // 进行中
 	PlayStatusCompleted PlayStatus = "completed" // 已完成
 	PlayStatusCancelled PlayStatus = "cancelled" // 已取消
 )
 
+// ConfirmStatus 完成后玩家确认状态枚举
+type ConfirmStatus string
+
+const (
+	ConfirmStatusPending   ConfirmStatus = "pending"   // 待确认（确认窗口内）
+	ConfirmStatusConfirmed ConfirmStatus = "confirmed" // 已确认（玩家确认或窗口到期自动确认）
+	ConfirmStatusDisputed  ConfirmStatus = "disputed"  // 申诉中（已扣费额被冻结）
+	ConfirmStatusResolved  ConfirmStatus = "resolved"  // 申诉已处理
+)
+
+// DisputeResolution 申诉处理结果枚举
+type DisputeResolution string
+
+const (
+	DisputeResolutionRefund  DisputeResolution = "refund"  // 全额退款
+	DisputeResolutionPartial DisputeResolution = "partial" // 部分退款
+	DisputeResolutionReject  DisputeResolution = "reject"  // 驳回申诉
+)
+
 // PlayRecord 游玩记录表
 type PlayRecord struct {
-	ID          uint            `json:"id" gorm:"primaryKey"`
-	PlayerID    uint            `json:"player_id" gorm:"not null;index:idx_record_player"`
-	ProviderID  uint            `json:"provider_id" gorm:"not null;index:idx_record_provider"`
-	StudioID    uint            `json:"studio_id" gorm:"not null;default:0"`
-	GameName    string          `json:"game_name" gorm:"size:100"`
-	GameMode    string          `json:"game_mode" gorm:"size:50"`
-	StartTime   time.Time       `json:"start_time" gorm:"not null"`
-	EndTime     *time.Time      `json:"end_time"`
-	Duration    uint            `json:"duration"`                                        // 游玩时长（分钟）
-	Amount      decimal.Decimal `json:"amount" gorm:"type:decimal(14,2);not null;default:0"` // 消费数额（按 settle_type 计）
-	SettleType  BalanceType     `json:"settle_type" gorm:"size:20;default:'money'"`      // 结算余额类型
-	Status      PlayStatus      `json:"status" gorm:"size:20;default:'active';index"`
-	Description string          `json:"description" gorm:"type:text"`
-	CreatedAt   time.Time       `json:"created_at"`
-	UpdatedAt   time.Time       `json:"updated_at"`
+	ID             uint            `json:"id" gorm:"primaryKey"`
+	PlayerID       uint            `json:"player_id" gorm:"not null;index:idx_record_player"`
+	ProviderID     uint            `json:"provider_id" gorm:"not null;index:idx_record_provider"`
+	StudioID       uint            `json:"studio_id" gorm:"not null;default:0"`
+	SubscriptionID *uint           `json:"subscription_id" gorm:"index"`            // 由订阅排期生成时指向订阅（已预付）
+	ScheduledAt    *time.Time      `json:"scheduled_at"`                            // 预约开始时间
+	GameID         uint            `json:"game_id" gorm:"not null;default:0;index"` // 游戏目录ID（0 = 历史自由文本，未归入目录）
+	GameModeID     uint            `json:"game_mode_id" gorm:"not null;default:0"`  // 游戏模式ID（0 = 未指定）
+	GameName       string          `json:"game_name" gorm:"size:100"`               // 目录中的规范名称
+	GameMode       string          `json:"game_mode" gorm:"size:50"`
+	StartTime      time.Time       `json:"start_time" gorm:"not null"`
+	EndTime        *time.Time      `json:"end_time"`
+	Duration       uint            `json:"duration"`                                            // 游玩时长（分钟）
+	Amount         decimal.Decimal `json:"amount" gorm:"type:decimal(14,2);not null;default:0"` // 消费数额（按 settle_type 计）
+	SettleType     BalanceType     `json:"settle_type" gorm:"size:20;default:'money'"`          // 结算余额类型
+	Status         PlayStatus      `json:"status" gorm:"size:20;default:'active';index"`
+	Description    string          `json:"description" gorm:"type:text"`
+
+	// 完成后的确认 / 申诉
+	ChargedAmount   decimal.Decimal   `json:"charged_amount" gorm:"type:decimal(14,2);not null;default:0"` // 完成时实际从余额扣除的数额
+	Settled         bool              `json:"settled" gorm:"not null;default:false"`                       // 完成时按余额结算（含数额为 0 与订阅场次），修正时据此联动余额
+	ConfirmStatus   ConfirmStatus     `json:"confirm_status" gorm:"size:20;index"`
+	ConfirmDeadline *time.Time        `json:"confirm_deadline" gorm:"index"` // 到期未操作则自动确认
+	ConfirmedAt     *time.Time        `json:"confirmed_at"`
+	DisputeReason   string            `json:"dispute_reason" gorm:"type:text"`
+	DisputedAt      *time.Time        `json:"disputed_at"`
+	Resolution      DisputeResolution `json:"resolution" gorm:"size:20"`
+	RefundAmount    decimal.Decimal   `json:"refund_amount" gorm:"type:decimal(14,2);not null;default:0"` // 申诉处理退回的数额
+	ResolutionNotes string            `json:"resolution_notes" gorm:"type:text"`
+	ResolverID      uint              `json:"resolver_id"`
+	ResolvedAt      *time.Time        `json:"resolved_at"`
+
+	StaleAt *time.Time `json:"stale_at"` // 进行中超时被标记为滞留的时间
+
+	// 取消：发起人、适用的取消规则与费用（规则、费用与策略条款为取消时的快照，不随策略修改而变）
+	CancelledBy             uint            `json:"cancelled_by"`
+	CancelPolicyID          *uint           `json:"cancel_policy_id"` // 取消时生效的策略；为空表示无策略（免费取消）
+	CancelRule              CancelRule      `json:"cancel_rule" gorm:"size:20"`
+	CancelFee               decimal.Decimal `json:"cancel_fee" gorm:"type:decimal(14,2);not null;default:0"`      // 按规则应收的费用
+	CancelFeeOwed           decimal.Decimal `json:"cancel_fee_owed" gorm:"type:decimal(14,2);not null;default:0"` // 余额不足未能扣除的部分
+	CancelFreeWindowMinutes int             `json:"cancel_free_window_minutes"`
+	CancelLateFee           decimal.Decimal `json:"cancel_late_fee" gorm:"type:decimal(14,2);not null;default:0"`
+	CancelNoShowFee         decimal.Decimal `json:"cancel_no_show_fee" gorm:"type:decimal(14,2);not null;default:0"`
+	CancelReason            string          `json:"cancel_reason" gorm:"type:text"`
+
+	// 进行中动态：时间线事件与心跳
+	LastActivityAt *time.Time `json:"last_activity_at"` // 最近一次事件或心跳；为空表示未启用活动监测
+	IdleAt         *time.Time `json:"idle_at"`          // 超过静默时限被标记为无活动的时间，有新活动时清空
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 
 	// 关联
-	Player   User    `json:"player,omitempty" gorm:"foreignKey:PlayerID"`
-	Provider User    `json:"provider,omitempty" gorm:"foreignKey:ProviderID"`
-	Studio   *Studio `json:"studio,omitempty" gorm:"foreignKey:StudioID"`
+	Player    User                 `json:"player,omitempty" gorm:"foreignKey:PlayerID"`
+	Provider  User                 `json:"provider,omitempty" gorm:"foreignKey:ProviderID"`
+	Studio    *Studio              `json:"studio,omitempty" gorm:"foreignKey:StudioID"`
+	Revisions []PlayRecordRevision `json:"revisions,omitempty" gorm:"foreignKey:PlayRecordID"`
+	Events    []PlayRecordEvent    `json:"events,omitempty" gorm:"foreignKey:PlayRecordID"`
+	Outcomes  []MatchOutcome       `json:"outcomes,omitempty" gorm:"foreignKey:PlayRecordID"`
+}
+
+// MatchResult 单场对局结果枚举
+type MatchResult string
+
+const (
+	MatchResultWin  MatchResult = "win"  // 胜
+	MatchResultLoss MatchResult = "loss" // 负
+	MatchResultDraw MatchResult = "draw" // 平
+)
+
+// MatchOutcome 一局陪玩中的单场对局结果（上分类陪玩可选填写）
+// provider_id / game_id 冗余自所属记录，便于按服务者、按游戏聚合胜率。
+type MatchOutcome struct {
+	ID           uint        `json:"id" gorm:"primaryKey"`
+	PlayRecordID uint        `json:"play_record_id" gorm:"not null;index"`
+	ProviderID   uint        `json:"provider_id" gorm:"not null;index:idx_outcome_provider_game,priority:1"`
+	GameID       uint        `json:"game_id" gorm:"not null;default:0;index:idx_outcome_provider_game,priority:2"`
+	Result       MatchResult `json:"result" gorm:"not null;size:10"`
+	RankBefore   string      `json:"rank_before" gorm:"size:50"` // 如「钻石III」
+	RankAfter    string      `json:"rank_after" gorm:"size:50"`
+	StarsGained  int         `json:"stars_gained"` // 本场星数变化，可为负
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+// PlayEventType 时间线事件类型枚举
+type PlayEventType string
+
+const (
+	PlayEventMatchStart  PlayEventType = "match_start"  // 开局
+	PlayEventMatchResult PlayEventType = "match_result" // 对局结果
+	PlayEventScore       PlayEventType = "score"        // 比分 / 战绩
+	PlayEventNote        PlayEventType = "note"         // 备注
+)
+
+// PlayRecordEvent 游玩记录时间线事件（服务者在进行中发布，玩家与服务者可见）
+type PlayRecordEvent struct {
+	ID           uint          `json:"id" gorm:"primaryKey"`
+	PlayRecordID uint          `json:"play_record_id" gorm:"not null;index"`
+	AuthorID     uint          `json:"author_id" gorm:"not null"`
+	Type         PlayEventType `json:"type" gorm:"not null;size:20"`
+	Content      string        `json:"content" gorm:"type:text"`
+	CreatedAt    time.Time     `json:"created_at" gorm:"index"`
+}
+
+// CancelRule 取消时适用的规则枚举
+type CancelRule string
+
+const (
+	CancelRuleFree     CancelRule = "free"     // 免费取消（玩家在免费窗口前取消，或无策略）
+	CancelRuleLate     CancelRule = "late"     // 玩家迟取消，收取迟取消费
+	CancelRuleNoShow   CancelRule = "no_show"  // 服务者登记玩家爽约，收取爽约费
+	CancelRuleProvider CancelRule = "provider" // 服务者主动取消，玩家不承担费用
+)
+
+// PolicyOwnerType 取消策略归属方枚举
+type PolicyOwnerType string
+
+const (
+	PolicyOwnerProvider PolicyOwnerType = "provider"
+	PolicyOwnerStudio   PolicyOwnerType = "studio"
+)
+
+// CancellationPolicy 取消策略：每个服务者 / 工作室各一份。工作室记录优先使用工作室策略，
+// 否则使用服务者策略；费用按记录的 settle_type 计。
+type CancellationPolicy struct {
+	ID                uint            `json:"id" gorm:"primaryKey"`
+	OwnerType         PolicyOwnerType `json:"owner_type" gorm:"not null;size:20;uniqueIndex:idx_policy_owner"`
+	OwnerID           uint            `json:"owner_id" gorm:"not null;uniqueIndex:idx_policy_owner"`
+	FreeWindowMinutes int             `json:"free_window_minutes" gorm:"not null;default:0"` // 预约开始前多少分钟以前取消免费
+	LateFee           decimal.Decimal `json:"late_fee" gorm:"type:decimal(14,2);not null;default:0"`
+	NoShowFee         decimal.Decimal `json:"no_show_fee" gorm:"type:decimal(14,2);not null;default:0"`
+	Notes             string          `json:"notes" gorm:"type:text"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+// SubscriptionStatus 订阅状态枚举
+type SubscriptionStatus string
+
+const (
+	SubscriptionStatusActive    SubscriptionStatus = "active"    // 生效中
+	SubscriptionStatusPaused    SubscriptionStatus = "paused"    // 已暂停（未使用场次已退款）
+	SubscriptionStatusCancelled SubscriptionStatus = "cancelled" // 已取消（未使用场次已退款）
+	SubscriptionStatusCompleted SubscriptionStatus = "completed" // 已用完
+)
+
+// Subscription 周期陪玩订阅：玩家向服务者按固定排期购买若干场，创建时按「单价 × 场次」预付，
+// 并按排期生成 booked 状态的游玩记录；暂停 / 取消时按未使用场次比例退款，恢复时重新预付并排期。
+type Subscription struct {
+	ID              uint               `json:"id" gorm:"primaryKey"`
+	PlayerID        uint               `json:"player_id" gorm:"not null;index"`
+	ProviderID      uint               `json:"provider_id" gorm:"not null;index"`
+	StudioID        uint               `json:"studio_id" gorm:"not null;default:0"`
+	GameID          uint               `json:"game_id" gorm:"not null;default:0"`
+	GameModeID      uint               `json:"game_mode_id" gorm:"not null;default:0"`
+	GameName        string             `json:"game_name" gorm:"size:100"`
+	GameMode        string             `json:"game_mode" gorm:"size:50"`
+	SettleType      BalanceType        `json:"settle_type" gorm:"size:20;default:'money'"`
+	Weekdays        string             `json:"weekdays" gorm:"size:20"`   // 排期星期，逗号分隔（0=周日 … 6=周六）
+	TimeOfDay       string             `json:"time_of_day" gorm:"size:5"` // 每场开始时间 HH:MM
+	PricePerSession decimal.Decimal    `json:"price_per_session" gorm:"type:decimal(14,2);not null"`
+	TotalSessions   int                `json:"total_sessions" gorm:"not null"`
+	TotalPrice      decimal.Decimal    `json:"total_price" gorm:"type:decimal(14,2);not null"`
+	UsedSessions    int                `json:"used_sessions" gorm:"not null;default:0"`
+	PaidSessions    int                `json:"paid_sessions" gorm:"not null;default:0"` // 当前已预付（含已使用）的场次
+	RefundedAmount  decimal.Decimal    `json:"refunded_amount" gorm:"type:decimal(14,2);not null;default:0"`
+	Status          SubscriptionStatus `json:"status" gorm:"size:20;default:'active';index"`
+	Notes           string             `json:"notes" gorm:"type:text"`
+	StartDate       time.Time          `json:"start_date"`
+	PausedAt        *time.Time         `json:"paused_at"`
+	CancelledAt     *time.Time         `json:"cancelled_at"`
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+
+	// 关联
+	Player   User         `json:"player,omitempty" gorm:"foreignKey:PlayerID"`
+	Provider User         `json:"provider,omitempty" gorm:"foreignKey:ProviderID"`
+	Sessions []PlayRecord `json:"sessions,omitempty" gorm:"foreignKey:SubscriptionID"`
+}
+
+// PlayRecordRevision 游玩记录修正历史（完成后修改时长/数额，每次一条）
+type PlayRecordRevision struct {
+	ID                uint            `json:"id" gorm:"primaryKey"`
+	PlayRecordID      uint            `json:"play_record_id" gorm:"not null;index"`
+	EditorID          uint            `json:"editor_id" gorm:"not null"`
+	OldDuration       uint            `json:"old_duration"`
+	NewDuration       uint            `json:"new_duration"`
+	OldAmount         decimal.Decimal `json:"old_amount" gorm:"type:decimal(14,2);not null;default:0"`
+	NewAmount         decimal.Decimal `json:"new_amount" gorm:"type:decimal(14,2);not null;default:0"`
+	BalanceAdjustment decimal.Decimal `json:"balance_adjustment" gorm:"type:decimal(14,2);not null;default:0"` // 对玩家余额的带符号调整（负为补扣，正为退回）
+	Reason            string          `json:"reason" gorm:"type:text"`
+	CreatedAt         time.Time       `json:"created_at"`
+
+	// 关联
+	Editor *User `json:"editor,omitempty" gorm:"foreignKey:EditorID"`
+}
+
+// Game 游戏目录
+type Game struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"uniqueIndex;not null;size:100"` // 规范名称
+	Icon      string    `json:"icon" gorm:"size:255"`
+	SortOrder int       `json:"sort_order" gorm:"default:0"`
+	IsActive  bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 关联
+	Modes   []GameMode  `json:"modes,omitempty" gorm:"foreignKey:GameID"`
+	Aliases []GameAlias `json:"aliases,omitempty" gorm:"foreignKey:GameID"`
+}
+
+// GameMode 游戏模式（隶属某个游戏）
+type GameMode struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	GameID    uint      `json:"game_id" gorm:"not null;uniqueIndex:idx_game_mode_name,priority:1"`
+	Name      string    `json:"name" gorm:"not null;size:50;uniqueIndex:idx_game_mode_name,priority:2"`
+	Icon      string    `json:"icon" gorm:"size:255"`
+	SortOrder int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GameAlias 游戏 / 模式别名（如「王者」「wzry」→ 王者荣耀），匹配时不区分大小写
+// mode_id = 0 表示游戏本身的别名；游戏别名全局唯一，模式别名在同一游戏内唯一（应用层校验）。
+type GameAlias struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	GameID    uint      `json:"game_id" gorm:"not null;uniqueIndex:idx_game_alias,priority:1"`
+	ModeID    uint      `json:"mode_id" gorm:"not null;default:0;uniqueIndex:idx_game_alias,priority:2"`
+	Alias     string    `json:"alias" gorm:"not null;size:100;uniqueIndex:idx_game_alias,priority:3;index"` // 小写存储
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TipSource 打赏支付来源枚举
+type TipSource string
+
+const (
+	TipSourceBalance  TipSource = "balance"  // 从玩家余额扣除
+	TipSourceExternal TipSource = "external" // 站外支付（微信/支付宝等），仅登记
+)
+
+// Tip 打赏表：玩家对已完成的一局向服务者打赏。
+// 打赏全额归服务者本人，不计入工作室流水（无 studio 维度），在服务者统计中单列。
+type Tip struct {
+	ID           uint            `json:"id" gorm:"primaryKey"`
+	PlayRecordID uint            `json:"play_record_id" gorm:"not null;index"`
+	PlayerID     uint            `json:"player_id" gorm:"not null;index"`
+	ProviderID   uint            `json:"provider_id" gorm:"not null;index"`
+	Source       TipSource       `json:"source" gorm:"not null;size:20"`
+	BalanceType  BalanceType     `json:"balance_type" gorm:"size:20"` // 从余额支付时扣除的余额类型
+	Amount       decimal.Decimal `json:"amount" gorm:"type:decimal(14,2);not null"`
+	Message      string          `json:"message" gorm:"size:255"`
+	CreatedAt    time.Time       `json:"created_at" gorm:"index"`
+
+	// 关联
+	Player     User        `json:"player,omitempty" gorm:"foreignKey:PlayerID"`
+	PlayRecord *PlayRecord `json:"play_record,omitempty" gorm:"foreignKey:PlayRecordID"`
 }
 
 // ReviewTargetType 评价对象类型枚举
@@ -183,6 +605,14 @@ const (
 	ReviewTargetStudio   ReviewTargetType = "studio"   // 评价工作室
 )
 
+// ReviewStatus 评价展示状态枚举
+type ReviewStatus string
+
+const (
+	ReviewStatusVisible ReviewStatus = "visible" // 正常展示
+	ReviewStatusHidden  ReviewStatus = "hidden"  // 被审核隐藏
+)
+
 // Review 评价表（多态：target_type + target_id 指向服务者或工作室）
 type Review struct {
 	ID           uint             `json:"id" gorm:"primaryKey"`
@@ -191,22 +621,183 @@ type Review struct {
 	TargetID     uint             `json:"target_id" gorm:"not null;index:idx_review_target,priority:2"`
 	Rating       int              `json:"rating" gorm:"not null"` // 1-5 星
 	Content      string           `json:"content" gorm:"type:text"`
-	Tags         string           `json:"tags" gorm:"size:255"` // JSON 数组字符串
+	LegacyTags   string           `json:"-" gorm:"column:tags;size:255"` // 历史自由文本标签（JSON 数组字符串），仅供迁移到标签词表
 	IsAnonymous  bool             `json:"is_anonymous" gorm:"default:false"`
-	PlayRecordID *uint            `json:"play_record_id" gorm:"index"` // 关联的游玩记录
+	PlayRecordID *uint            `json:"play_record_id" gorm:"uniqueIndex:uniq_review_play_record"` // 关联的游玩记录（每条记录至多一条评价，唯一索引兜底并发提交；NULL 不受限）
+	Verified     bool             `json:"verified" gorm:"default:false;index"`                       // 由本人已完成的真实陪玩记录支撑
+	Status       ReviewStatus     `json:"status" gorm:"size:20;default:'visible';index"`             // 隐藏的评价不出现在公开列表与汇总中
+	EditCount    int              `json:"edit_count" gorm:"not null;default:0"`                      // 修改次数，大于 0 即显示「已编辑」
+	EditedAt     *time.Time       `json:"edited_at"`                                                 // 最近一次修改时间
+	HelpfulCount int              `json:"helpful_count" gorm:"not null;default:0;index"`             // 「有帮助」票数
 	CreatedAt    time.Time        `json:"created_at"`
 	UpdatedAt    time.Time        `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt   `json:"-" gorm:"index"` // 审核删除为软删除，保留审核历史
 
 	// 关联
-	Player     User        `json:"player,omitempty" gorm:"foreignKey:PlayerID"`
-	PlayRecord *PlayRecord `json:"play_record,omitempty" gorm:"foreignKey:PlayRecordID"`
+	Player     User             `json:"player,omitempty" gorm:"foreignKey:PlayerID"`
+	PlayRecord *PlayRecord      `json:"play_record,omitempty" gorm:"foreignKey:PlayRecordID"`
+	Reply      *ReviewReply     `json:"reply,omitempty" gorm:"foreignKey:ReviewID"`
+	Reports    []ReviewReport   `json:"reports,omitempty" gorm:"foreignKey:ReviewID"`
+	Tags       []ReviewTag      `json:"tags,omitempty" gorm:"many2many:review_tag_links"`
+	Revisions  []ReviewRevision `json:"revisions,omitempty" gorm:"foreignKey:ReviewID"`
+}
+
+// TagPolarity 评价标签倾向枚举
+type TagPolarity string
+
+const (
+	TagPositive TagPolarity = "positive" // 好评标签
+	TagNegative TagPolarity = "negative" // 差评标签
+)
+
+// ReviewTag 评价标签词表；评价与标签经 review_tag_links 多对多关联
+type ReviewTag struct {
+	ID        uint        `json:"id" gorm:"primaryKey"`
+	Name      string      `json:"name" gorm:"not null;size:20;uniqueIndex"`
+	Polarity  TagPolarity `json:"polarity" gorm:"not null;size:20;index"`
+	SortOrder int         `json:"sort_order" gorm:"default:0"`
+	IsActive  bool        `json:"is_active" gorm:"default:true"` // 停用后不能再选用，已有评价保留
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// RatingScore 服务者 / 工作室的排名分：公开评价按时间衰减加权后向平台均分做贝叶斯平滑。
+// 评价变动时只重算对应对象；定时任务重算较久未刷新的记录，使衰减随时间生效。
+type RatingScore struct {
+	ID              uint             `json:"id" gorm:"primaryKey"`
+	TargetType      ReviewTargetType `json:"target_type" gorm:"not null;size:20;uniqueIndex:idx_score_target,priority:1"`
+	TargetID        uint             `json:"target_id" gorm:"not null;uniqueIndex:idx_score_target,priority:2"`
+	Score           float64          `json:"score" gorm:"not null;default:0;index"`      // 排名分（1-5）
+	DecayedAverage  float64          `json:"decayed_average" gorm:"not null;default:0"`  // 衰减加权平均分（未平滑）
+	EffectiveWeight float64          `json:"effective_weight" gorm:"not null;default:0"` // 衰减后的有效评价数
+	ReviewCount     int64            `json:"review_count" gorm:"not null;default:0"`     // 公开评价数
+	PlatformMean    float64          `json:"platform_mean" gorm:"not null;default:0"`    // 计算时使用的平台均分
+	RefreshedAt     time.Time        `json:"refreshed_at" gorm:"index"`
+}
+
+// ReviewRevision 评价修改历史：每次修改前保存一份旧版本
+type ReviewRevision struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ReviewID    uint      `json:"review_id" gorm:"not null;index"`
+	Rating      int       `json:"rating" gorm:"not null"`
+	Content     string    `json:"content" gorm:"type:text"`
+	TagNames    string    `json:"tag_names" gorm:"size:255"` // 旧版本的标签名称，逗号分隔
+	IsAnonymous bool      `json:"is_anonymous"`
+	CreatedAt   time.Time `json:"created_at"` // 被替换的时间
+}
+
+// ReviewReply 被评价方（服务者本人或工作室所有者）对评价的公开回复，每条评价至多一条
+type ReviewReply struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ReviewID  uint      `json:"review_id" gorm:"not null;uniqueIndex"`
+	AuthorID  uint      `json:"author_id" gorm:"not null"`
+	Content   string    `json:"content" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReportStatus 评价举报处理状态枚举
+type ReportStatus string
+
+const (
+	ReportStatusPending ReportStatus = "pending" // 待处理
+	ReportStatusHandled ReportStatus = "handled" // 已处理（随审核操作一并结案）
+)
+
+// ReviewReport 评价举报：每个用户对同一条评价只能举报一次
+type ReviewReport struct {
+	ID         uint         `json:"id" gorm:"primaryKey"`
+	ReviewID   uint         `json:"review_id" gorm:"not null;uniqueIndex:idx_report_review_reporter,priority:1"`
+	ReporterID uint         `json:"reporter_id" gorm:"not null;uniqueIndex:idx_report_review_reporter,priority:2"`
+	Reason     string       `json:"reason" gorm:"type:text;not null"`
+	Status     ReportStatus `json:"status" gorm:"size:20;default:'pending';index"`
+	HandledAt  *time.Time   `json:"handled_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+
+	// 关联
+	Reporter User `json:"reporter,omitempty" gorm:"foreignKey:ReporterID"`
+}
+
+// ReviewHelpfulVote 评价「有帮助」投票：每个用户对同一条评价只能投一票
+type ReviewHelpfulVote struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ReviewID  uint      `json:"review_id" gorm:"not null;uniqueIndex:idx_helpful_review_user,priority:1"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_helpful_review_user,priority:2"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ModerationAction 评价审核操作枚举
+type ModerationAction string
+
+const (
+	ModerationHide    ModerationAction = "hide"    // 隐藏
+	ModerationRestore ModerationAction = "restore" // 恢复展示（含恢复已删除的评价）
+	ModerationDelete  ModerationAction = "delete"  // 删除（软删除）
+	ModerationDismiss ModerationAction = "dismiss" // 驳回举报，不改变评价
+)
+
+// ReviewModeration 评价审核历史（只追加，不修改）
+type ReviewModeration struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	ReviewID    uint             `json:"review_id" gorm:"not null;index"`
+	ModeratorID uint             `json:"moderator_id" gorm:"not null"`
+	Action      ModerationAction `json:"action" gorm:"not null;size:20"`
+	Notes       string           `json:"notes" gorm:"type:text"`
+	CreatedAt   time.Time        `json:"created_at"`
+
+	// 关联
+	Moderator User `json:"moderator,omitempty" gorm:"foreignKey:ModeratorID"`
+}
+
+// NotificationType 站内通知类型枚举
+type NotificationType string
+
+const (
+	NotificationPlayRecordStale NotificationType = "play_record_stale" // 陪玩长时间未结束
+	NotificationTipReceived     NotificationType = "tip_received"      // 收到打赏
+	NotificationPlayRecordIdle  NotificationType = "play_record_idle"  // 进行中的陪玩长时间无活动
+	NotificationStudioRelation  NotificationType = "studio_relation"   // 工作室归属变动（移除、退出、重新申请、邀请加入）
+	NotificationStudioStatus    NotificationType = "studio_status"     // 工作室停用 / 重新启用及余额处理
+)
+
+// Notification 站内通知表
+type Notification struct {
+	ID        uint             `json:"id" gorm:"primaryKey"`
+	UserID    uint             `json:"user_id" gorm:"not null;index:idx_notification_user"`
+	Type      NotificationType `json:"type" gorm:"not null;size:50"`
+	Title     string           `json:"title" gorm:"size:100"`
+	Content   string           `json:"content" gorm:"type:text"`
+	RefID     uint             `json:"ref_id"` // 关联对象ID（按 type 解释）
+	ReadAt    *time.Time       `json:"read_at"`
+	CreatedAt time.Time        `json:"created_at" gorm:"index"`
 }
 
 // TableName 设置表名
-func (User) TableName() string                   { return "users" }
-func (Studio) TableName() string                 { return "studios" }
-func (ProviderStudioRelation) TableName() string { return "provider_studio_relations" }
-func (Balance) TableName() string                { return "balances" }
-func (BalanceTransaction) TableName() string     { return "balance_transactions" }
-func (PlayRecord) TableName() string             { return "play_records" }
-func (Review) TableName() string                 { return "reviews" }
+func (User) TableName() string                    { return "users" }
+func (Studio) TableName() string                  { return "studios" }
+func (ProviderStudioRelation) TableName() string  { return "provider_studio_relations" }
+func (RelationStatusLog) TableName() string       { return "relation_status_logs" }
+func (StudioInvite) TableName() string            { return "studio_invites" }
+func (StudioStaff) TableName() string             { return "studio_staff" }
+func (StudioTransfer) TableName() string          { return "studio_transfers" }
+func (StudioApplicationPolicy) TableName() string { return "studio_application_policies" }
+func (Balance) TableName() string                 { return "balances" }
+func (BalanceTransaction) TableName() string      { return "balance_transactions" }
+func (PlayRecord) TableName() string              { return "play_records" }
+func (Review) TableName() string                  { return "reviews" }
+func (ReviewReply) TableName() string             { return "review_replies" }
+func (ReviewRevision) TableName() string          { return "review_revisions" }
+func (ReviewTag) TableName() string               { return "review_tags" }
+func (RatingScore) TableName() string             { return "rating_scores" }
+func (ReviewReport) TableName() string            { return "review_reports" }
+func (ReviewModeration) TableName() string        { return "review_moderations" }
+func (Notification) TableName() string            { return "notifications" }
+func (PlayRecordRevision) TableName() string      { return "play_record_revisions" }
+func (Game) TableName() string                    { return "games" }
+func (GameMode) TableName() string                { return "game_modes" }
+func (GameAlias) TableName() string               { return "game_aliases" }
+func (Tip) TableName() string                     { return "tips" }
+func (PlayRecordEvent) TableName() string         { return "play_record_events" }
+func (MatchOutcome) TableName() string            { return "match_outcomes" }
+func (Subscription) TableName() string            { return "subscriptions" }
+func (CancellationPolicy) TableName() string      { return "cancellation_policies" }
@@ -15,7 +15,7 @@ func SetupRoutes(r *gin.Engine) {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "http://127.0.0.1:3000"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", controllers.StudioHeader},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 	}))
@@ -23,10 +23,22 @@ func SetupRoutes(r *gin.Engine) {
 	// 初始化控制器
 	userController := &controllers.UserController{}
 	studioController := &controllers.StudioController{}
+	studioInviteController := &controllers.StudioInviteController{}
+	studioStaffController := &controllers.StudioStaffController{}
+	studioTransferController := &controllers.StudioTransferController{}
 	balanceController := &controllers.BalanceController{}
 	playRecordController := &controllers.PlayRecordController{}
 	reviewController := &controllers.ReviewController{}
 	dashboardController := &controllers.DashboardController{}
+	notificationController := &controllers.NotificationController{}
+	gameController := &controllers.GameController{}
+	tipController := &controllers.TipController{}
+	providerController := &controllers.ProviderController{}
+	subscriptionController := &controllers.SubscriptionController{}
+	cancellationPolicyController := &controllers.CancellationPolicyController{}
+	moderationController := &controllers.ModerationController{}
+	reviewTagController := &controllers.ReviewTagController{}
+	applicationPolicyController := &controllers.ApplicationPolicyController{}
 
 	// API分组
 	api := r.Group("/api/v1")
@@ -44,11 +56,18 @@ func SetupRoutes(r *gin.Engine) {
 
 		public.GET("/studios", studioController.GetStudioList)
 		public.GET("/studios/:id", studioController.GetStudioByID)
+		public.GET("/studios/:id/application-policy", applicationPolicyController.Get)
 		public.GET("/users/:id", userController.GetUserByID)
+		public.GET("/games", gameController.List)
+		public.GET("/providers", providerController.List)
+		public.GET("/providers/:id", providerController.Profile)
+		public.GET("/providers/:id/match-stats", providerController.MatchStatsByProvider)
+		public.GET("/providers/:id/cancellation-policy", cancellationPolicyController.Effective)
 
 		// 公开评价查看
 		public.GET("/reviews", reviewController.ListByTarget)
 		public.GET("/reviews/summary", reviewController.Summary)
+		public.GET("/review-tags", reviewTagController.List)
 	}
 
 	// 需要认证的路由
@@ -59,6 +78,19 @@ func SetupRoutes(r *gin.Engine) {
 		auth.GET("/profile", userController.GetProfile)
 		auth.PUT("/profile", userController.UpdateProfile)
 
+		// 站内通知
+		auth.GET("/notifications", notificationController.List)
+		auth.PUT("/notifications/read-all", notificationController.MarkAllRead)
+		auth.PUT("/notifications/:id/read", notificationController.MarkRead)
+
+		// 评价回复（被评价的服务者本人或工作室所有者）
+		auth.POST("/reviews/:id/reply", reviewController.Reply)
+		auth.PUT("/reviews/:id/reply", reviewController.UpdateReply)
+		auth.DELETE("/reviews/:id/reply", reviewController.DeleteReply)
+		auth.POST("/reviews/:id/report", moderationController.Report)
+		auth.POST("/reviews/:id/helpful", reviewController.MarkHelpful)
+		auth.DELETE("/reviews/:id/helpful", reviewController.UnmarkHelpful)
+
 		// 玩家路由
 		player := auth.Group("/player")
 		player.Use(middleware.RequireRole(models.RolePlayer))
@@ -68,8 +100,22 @@ func SetupRoutes(r *gin.Engine) {
 			player.GET("/balances/provider/:provider_id", balanceController.GetBalanceByProvider)
 			player.GET("/balances/:id/transactions", balanceController.GetBalanceTransactions)
 			player.GET("/records", playRecordController.ListMine)
+			player.GET("/records/export", playRecordController.ExportMine)
+			player.GET("/records/:id", playRecordController.Detail)
+			player.PUT("/records/:id/confirm", playRecordController.Confirm)
+			player.PUT("/records/:id/dispute", playRecordController.Dispute)
+			player.PUT("/records/:id/cancel", playRecordController.CancelByPlayer)
+			player.GET("/records/:id/revisions", playRecordController.Revisions)
+			player.POST("/records/:id/tips", tipController.Create)
 			player.POST("/reviews", reviewController.Create)
 			player.GET("/reviews", reviewController.ListMine)
+			player.PUT("/reviews/:id", reviewController.Update)
+			player.GET("/reviews/:id/revisions", reviewController.Revisions)
+			player.POST("/subscriptions", subscriptionController.Create)
+			player.GET("/subscriptions", subscriptionController.ListMine)
+			player.PUT("/subscriptions/:id/pause", subscriptionController.Pause)
+			player.PUT("/subscriptions/:id/resume", subscriptionController.Resume)
+			player.PUT("/subscriptions/:id/cancel", subscriptionController.Cancel)
 		}
 
 		// 服务者路由
@@ -83,10 +129,28 @@ func SetupRoutes(r *gin.Engine) {
 			provider.POST("/balances/deduct", balanceController.Deduct)
 			provider.POST("/balances/refund", balanceController.Refund)
 			provider.POST("/play-records", playRecordController.Create)
+			provider.PUT("/play-records/:id/start", playRecordController.Start)
 			provider.PUT("/play-records/:id/complete", playRecordController.Complete)
 			provider.PUT("/play-records/:id/cancel", playRecordController.Cancel)
+			provider.PUT("/play-records/:id/resolve", playRecordController.ResolveDispute)
+			provider.PUT("/play-records/:id/amend", playRecordController.Amend)
+			provider.GET("/play-records/:id/revisions", playRecordController.Revisions)
 			provider.GET("/play-records", playRecordController.ListHosted)
+			provider.GET("/play-records/export", playRecordController.ExportHosted)
+			provider.GET("/play-records/:id", playRecordController.Detail)
+			provider.POST("/play-records/:id/events", playRecordController.PostEvent)
+			provider.PUT("/play-records/:id/heartbeat", playRecordController.Heartbeat)
+			provider.POST("/play-records/:id/outcomes", playRecordController.AddOutcome)
+			provider.DELETE("/play-records/outcomes/:outcome_id", playRecordController.DeleteOutcome)
 			provider.GET("/relations", studioController.GetMyRelations)
+			provider.POST("/invites/redeem", studioInviteController.Redeem)
+			provider.GET("/tips", tipController.ListReceived)
+			provider.GET("/cancellation-policy", cancellationPolicyController.GetMine)
+			provider.PUT("/cancellation-policy", cancellationPolicyController.UpsertMine)
+			provider.GET("/subscriptions", subscriptionController.ListHosted)
+			provider.PUT("/subscriptions/:id/pause", subscriptionController.Pause)
+			provider.PUT("/subscriptions/:id/resume", subscriptionController.Resume)
+			provider.PUT("/subscriptions/:id/cancel", subscriptionController.Cancel)
 		}
 
 		// 工作室路由
@@ -94,20 +158,47 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			// 任何认证用户都可以申请加入工作室
 			studio.POST("/:id/apply", studioController.ApplyToJoinStudio)
+			studio.POST("/:id/leave", studioController.LeaveStudio)
 
 			// 只有工作室角色可以创建和管理工作室
 			studioOnly := studio.Group("/")
 			studioOnly.Use(middleware.RequireRole(models.RoleStudio))
 			{
+				studioOnly.GET("/mine", studioStaffController.Mine)
 				studioOnly.GET("/dashboard", dashboardController.StudioDashboard)
+				studioOnly.GET("/dashboard/overview", dashboardController.StudioOverview)
 				studioOnly.GET("/members", studioController.GetStudioMembers)
+				studioOnly.GET("/:id/dashboard", dashboardController.StudioDashboard)
+				studioOnly.GET("/:id/members", studioController.GetStudioMembers)
 				studioOnly.POST("/", studioController.CreateStudio)
 				studioOnly.PUT("/:id", studioController.UpdateStudio)
+				studioOnly.PUT("/:id/deactivate", studioController.Deactivate)
+				studioOnly.PUT("/:id/reactivate", studioController.Reactivate)
 				studioOnly.GET("/:id/applications", studioController.GetStudioApplications)
+				studioOnly.DELETE("/:id/members/:provider_id", studioController.RemoveMember)
+				studioOnly.POST("/:id/invites", studioInviteController.Create)
+				studioOnly.GET("/:id/invites", studioInviteController.List)
+				studioOnly.DELETE("/:id/invites/:invite_id", studioInviteController.Revoke)
+				studioOnly.GET("/:id/staff", studioStaffController.List)
+				studioOnly.POST("/:id/staff", studioStaffController.Add)
+				studioOnly.PUT("/:id/staff/:user_id", studioStaffController.UpdateRole)
+				studioOnly.DELETE("/:id/staff/:user_id", studioStaffController.Remove)
+				studioOnly.POST("/:id/transfer", studioTransferController.Initiate)
+				studioOnly.DELETE("/:id/transfer", studioTransferController.Cancel)
+				studioOnly.GET("/:id/transfers", studioTransferController.History)
+				studioOnly.GET("/transfers/incoming", studioTransferController.Incoming)
+				studioOnly.PUT("/transfers/:id/accept", studioTransferController.Accept)
+				studioOnly.PUT("/transfers/:id/decline", studioTransferController.Decline)
+				studioOnly.GET("/:id/cancellation-policy", cancellationPolicyController.GetStudio)
+				studioOnly.PUT("/:id/cancellation-policy", cancellationPolicyController.UpsertStudio)
+				studioOnly.PUT("/:id/application-policy", applicationPolicyController.Upsert)
 				studioOnly.PUT("/applications/:id", studioController.ProcessApplication)
 				studioOnly.POST("/balances", balanceController.Recharge)
 				studioOnly.POST("/balances/deduct", balanceController.Deduct)
 				studioOnly.POST("/balances/refund", balanceController.Refund)
+				studioOnly.PUT("/play-records/:id/resolve", playRecordController.ResolveDispute)
+				studioOnly.PUT("/play-records/:id/amend", playRecordController.Amend)
+				studioOnly.GET("/play-records/:id/revisions", playRecordController.Revisions)
 			}
 		}
 
@@ -117,5 +208,29 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			admin.GET("/users", userController.GetUserList)
 		}
+
+		// 平台管理员路由（账号由 ADMIN_USERNAMES 指定，工作室账号无权访问）
+		platform := auth.Group("/admin")
+		platform.Use(middleware.RequireRole(models.RoleAdmin))
+		{
+			// 游戏目录管理
+			platform.POST("/games", gameController.CreateGame)
+			platform.PUT("/games/:id", gameController.UpdateGame)
+			platform.POST("/games/:id/modes", gameController.AddMode)
+			platform.POST("/games/:id/aliases", gameController.AddAlias)
+			platform.DELETE("/games/aliases/:id", gameController.DeleteAlias)
+
+			// 评价标签词表
+			platform.POST("/review-tags", reviewTagController.Create)
+			platform.PUT("/review-tags/:id", reviewTagController.Update)
+
+			// 评价审核
+			platform.GET("/reviews/queue", moderationController.Queue)
+			platform.GET("/reviews/:id/moderations", moderationController.History)
+			platform.PUT("/reviews/:id/hide", moderationController.Hide)
+			platform.PUT("/reviews/:id/restore", moderationController.Restore)
+			platform.PUT("/reviews/:id/dismiss", moderationController.Dismiss)
+			platform.DELETE("/reviews/:id", moderationController.Delete)
+		}
 	}
 }
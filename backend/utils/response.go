@@ -19,6 +19,7 @@ type PageResponse struct {
 	Total    int64       `json:"total"`
 	Page     int         `json:"page"`
 	PageSize int         `json:"page_size"`
+	Summary  interface{} `json:"summary,omitempty"` // 对筛选结果的聚合（可选）
 }
 
 // Success 成功响应
@@ -88,4 +89,15 @@ func PageSuccess(c *gin.Context, list interface{}, total int64, page, pageSize i
 		Page:     page,
 		PageSize: pageSize,
 	})
-}
\ No newline at end of file
+}
+
+// PageSuccessWithSummary 带聚合信息的分页成功响应
+func PageSuccessWithSummary(c *gin.Context, list interface{}, total int64, page, pageSize int, summary interface{}) {
+	Success(c, PageResponse{
+		List:     list,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+		Summary:  summary,
+	})
+}
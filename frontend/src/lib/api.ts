@@ -163,7 +163,7 @@ class ApiClient {
     target_id: number;
     rating: number;
     content?: string;
-    tags?: string;
+    tags?: string[];
     is_anonymous?: boolean;
     play_record_id?: number;
   }) => this.request<Review>('post', '/player/reviews', data);
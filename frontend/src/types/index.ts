@@ -141,6 +141,15 @@ export interface PlayRecord {
   studio?: Studio;
 }
 
+// 评价标签
+export interface ReviewTag {
+  id: number;
+  name: string;
+  polarity: 'positive' | 'negative';
+  sort_order: number;
+  is_active: boolean;
+}
+
 // 评价接口
 export interface Review {
   id: number;
@@ -149,7 +158,7 @@ export interface Review {
   target_id: number;
   rating: number;
   content?: string;
-  tags?: string;
+  tags?: ReviewTag[];
   is_anonymous: boolean;
   play_record_id?: number;
   created_at: string;